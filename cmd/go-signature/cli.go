@@ -0,0 +1,505 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/cliapp"
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/common/opio"
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	flags2 "github.com/qiaopengjun5162/go-rpc-service/flags"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rest"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rpc"
+)
+
+// runRpc builds the gRPC service lifecycle from the cli flags.
+//
+// It loads the configuration, the chain registry and the master key, connects
+// to the database, refuses to start if an applied migration has been modified,
+// and hands them to rpc.NewRpcServer. The returned server is started and
+// stopped by the cliapp lifecycle machinery.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//   - shutdown: A cancel function that can be used to request an early shutdown.
+//
+// Returns:
+//   - cliapp.Lifecycle: The rpc server lifecycle.
+//   - error: An error if the database connection or server setup fails.
+func runRpc(ctx *cli.Context, shutdown context.CancelCauseFunc) (cliapp.Lifecycle, error) {
+	log.Info("running grpc services...")
+	cfg, err := config.NewConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := chains.FromConfig(cfg.Chains)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chain config: %w", err)
+	}
+	grpcServerCfg := &rpc.RpcServerConfig{
+		GrpcHostname: cfg.RpcServer.Host,
+		GrpcPort:     cfg.RpcServer.Port,
+		MetricsHost:  cfg.RpcMetricsServer.Host,
+		MetricsPort:  cfg.RpcMetricsServer.Port,
+		AddressPool: issuer.PoolConfig{
+			Size:           cfg.AddressPool.Size,
+			LowWater:       cfg.AddressPool.LowWater,
+			RefillInterval: cfg.AddressPool.RefillInterval,
+		},
+		Chains: registry,
+	}
+	keyCipher, err := envelope.LoadKeyring(cfg.MasterKey.Key, cfg.MasterKey.File, cfg.MasterKey.Version, cfg.MasterKey.RetiredFile)
+	if err != nil {
+		log.Error("failed to load master key", "err", err)
+		return nil, err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.Database)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return nil, err
+	}
+	if err := db.PrepareSchema(cfg.Migrations); err != nil {
+		log.Error("failed to prepare the database schema", "err", err)
+		return nil, errors.Join(err, db.Close())
+	}
+	return rpc.NewRpcServer(db, keyCipher, grpcServerCfg)
+}
+
+// runRestApi builds the REST api lifecycle from the cli flags.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//   - shutdown: A cancel function that can be used to request an early shutdown.
+//
+// Returns:
+//   - cliapp.Lifecycle: The api lifecycle.
+//   - error: An error if the api cannot be initialized.
+func runRestApi(ctx *cli.Context, shutdown context.CancelCauseFunc) (cliapp.Lifecycle, error) {
+	log.Info("running api...")
+	cfg, err := config.NewConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rest.NewApi(ctx.Context, &cfg)
+}
+
+// runMigrations connects to the database and applies the pending migrations
+// in version order. They are embedded in the binary unless --migrations-dir
+// points to another folder.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the connection or any migration fails, or if an
+//     applied migration has been modified.
+func runMigrations(ctx *cli.Context) error {
+	log.Info("running migrations...")
+	return withMigrator(ctx, func(migrator *database.Migrator) error {
+		count, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		log.Info("migrations up to date", "applied", count)
+		return nil
+	})
+}
+
+// runMigrateDown reverts the last --steps applied migrations.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if a migration cannot be reverted.
+func runMigrateDown(ctx *cli.Context) error {
+	steps := ctx.Int(flags2.StepsFlag.Name)
+	if steps < 1 {
+		return fmt.Errorf("invalid steps %d", steps)
+	}
+	return withMigrator(ctx, func(migrator *database.Migrator) error {
+		count, err := migrator.Down(steps)
+		if err != nil {
+			return err
+		}
+		log.Info("reverted migrations", "reverted", count)
+		return nil
+	})
+}
+
+// runMigrateRedo reverts the last applied migration and applies it again.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the migration cannot be reverted or applied.
+func runMigrateRedo(ctx *cli.Context) error {
+	return withMigrator(ctx, func(migrator *database.Migrator) error {
+		return migrator.Redo()
+	})
+}
+
+// runMigrateStatus prints every migration with whether it is applied, pending,
+// modified since it was applied or missing its file.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the applied migrations cannot be queried.
+func runMigrateStatus(ctx *cli.Context) error {
+	return withMigrator(ctx, func(migrator *database.Migrator) error {
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			switch {
+			case st.Missing:
+				state = "missing"
+			case st.Modified:
+				state = "modified"
+			case st.Applied:
+				state = "applied"
+			}
+			appliedAt := "-"
+			if st.Applied {
+				appliedAt = time.Unix(int64(st.AppliedAt), 0).UTC().Format(time.RFC3339)
+			}
+			fmt.Printf("%05d\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt)
+		}
+		return nil
+	})
+}
+
+// withMigrator runs fn with a migrator for the configured migrations folder,
+// or for the embedded migrations when none is configured.
+func withMigrator(ctx *cli.Context, fn func(migrator *database.Migrator) error) error {
+	cfg, err := config.NewConfig(ctx)
+	if err != nil {
+		return err
+	}
+	return withDatabase(ctx, func(db *database.DB) error {
+		migrator, err := db.NewMigrator(database.MigrationsFS(cfg.Migrations))
+		if err != nil {
+			return err
+		}
+		return fn(migrator)
+	})
+}
+
+// withDatabase connects to the configured database, runs fn and closes the
+// connection again. It backs the one-shot administrative commands.
+func withDatabase(ctx *cli.Context, fn func(db *database.DB) error) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	cfg, err := config.NewConfig(ctx)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.Database)
+	if err != nil {
+		log.Error("failed to connect to database", "err", err)
+		return err
+	}
+	defer func(db *database.DB) {
+		if err := db.Close(); err != nil {
+			log.Error("failed to close database", "err", err)
+		}
+	}(db)
+	return fn(db)
+}
+
+// runIssueToken issues a consumer token with the requested scopes for a
+// business id and prints it. The token is used as consumer_token for the rpc
+// service and as API key for the rest api. It is only shown once; the database
+// keeps its hash.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the business id is missing or the token cannot be stored.
+func runIssueToken(ctx *cli.Context) error {
+	return withDatabase(ctx, func(db *database.DB) error {
+		token, row, err := auth.IssueToken(db.Tokens, ctx.String(flags2.BusinessIdFlag.Name), ctx.StringSlice(flags2.ScopesFlag.Name))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("token_id:    %s\nbusiness_id: %s\nscopes:      %s\ntoken:       %s\n", row.GUID, row.BusinessId, row.Scopes, token)
+		return nil
+	})
+}
+
+// runRevokeToken revokes the consumer token with the given guid.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the guid is invalid or unknown, or the update fails.
+func runRevokeToken(ctx *cli.Context) error {
+	guid, err := uuid.Parse(ctx.String(flags2.TokenIdFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid token id: %w", err)
+	}
+	return withDatabase(ctx, func(db *database.DB) error {
+		found, err := db.Tokens.RevokeToken(guid)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("token %s not found", guid)
+		}
+		log.Info("revoked consumer token", "token_id", guid)
+		return nil
+	})
+}
+
+// runListTokens prints the consumer tokens issued to a business id.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the query fails.
+func runListTokens(ctx *cli.Context) error {
+	return withDatabase(ctx, func(db *database.DB) error {
+		tokenList, err := db.Tokens.QueryTokensByBusId(ctx.String(flags2.BusinessIdFlag.Name))
+		if err != nil {
+			return err
+		}
+		for _, token := range tokenList {
+			fmt.Printf("%s\t%s\t%s\trevoked=%t\t%d\n", token.GUID, token.BusinessId, token.Scopes, token.Revoked, token.Timestamp)
+		}
+		return nil
+	})
+}
+
+// runReseal re-encrypts the plaintext keys and the keys and seeds sealed under
+// a retired master key with the current master key. The retired keys are read
+// from --master-key-retired-file.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the master keys cannot be loaded or a row cannot be resealed.
+func runReseal(ctx *cli.Context) error {
+	cfg, err := config.NewConfig(ctx)
+	if err != nil {
+		return err
+	}
+	keyCipher, err := envelope.LoadKeyring(cfg.MasterKey.Key, cfg.MasterKey.File, cfg.MasterKey.Version, cfg.MasterKey.RetiredFile)
+	if err != nil {
+		return fmt.Errorf("failed to load master key: %w", err)
+	}
+	return withDatabase(ctx, func(db *database.DB) error {
+		keyCount, seedCount, err := issuer.NewIssuer(db, keyCipher, nil, issuer.PoolConfig{}).Reseal(ctx.Context)
+		if err != nil {
+			return err
+		}
+		log.Info("resealed keys and seeds", "keys", keyCount, "seeds", seedCount, "key_version", keyCipher.Version())
+		return nil
+	})
+}
+
+// runExportSeed prints the BIP-39 mnemonic the HD keys of a business are
+// derived from. With the derivation paths listed by ListSupportedChains, it
+// recovers the business's HD keys in any BIP-39 wallet, or through seed
+// import in a new database.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the master key cannot be loaded or the business has
+//     no seed.
+func runExportSeed(ctx *cli.Context) error {
+	return withIssuer(ctx, func(i *issuer.Issuer) error {
+		mnemonic, err := i.ExportSeed(ctx.String(flags2.BusinessIdFlag.Name))
+		if err != nil {
+			return err
+		}
+		fmt.Println(mnemonic)
+		return nil
+	})
+}
+
+// runImportSeed restores the mnemonic read from stdin as the seed of a
+// business. The mnemonic is not taken as a flag so that it stays out of the
+// process list and the shell history.
+//
+// Parameters:
+//   - ctx: The cli.Context carrying the flag values.
+//
+// Returns:
+//   - error: An error if the mnemonic is invalid or the business already has
+//     another seed.
+func runImportSeed(ctx *cli.Context) error {
+	mnemonic, err := io.ReadAll(io.LimitReader(os.Stdin, 4096))
+	if err != nil {
+		return fmt.Errorf("failed to read the mnemonic: %w", err)
+	}
+	return withIssuer(ctx, func(i *issuer.Issuer) error {
+		businessId := ctx.String(flags2.BusinessIdFlag.Name)
+		if err := i.ImportSeed(businessId, string(mnemonic)); err != nil {
+			return err
+		}
+		log.Info("imported seed", "business_id", businessId)
+		return nil
+	})
+}
+
+// withIssuer loads the master keys, connects to the database and runs fn
+// with an issuer for the configured chains.
+func withIssuer(ctx *cli.Context, fn func(i *issuer.Issuer) error) error {
+	cfg, err := config.NewConfig(ctx)
+	if err != nil {
+		return err
+	}
+	keyCipher, err := envelope.LoadKeyring(cfg.MasterKey.Key, cfg.MasterKey.File, cfg.MasterKey.Version, cfg.MasterKey.RetiredFile)
+	if err != nil {
+		return fmt.Errorf("failed to load master key: %w", err)
+	}
+	return withDatabase(ctx, func(db *database.DB) error {
+		return fn(issuer.NewIssuer(db, keyCipher, nil, issuer.PoolConfig{}))
+	})
+}
+
+// NewCli creates the go-signature cli application.
+//
+// Parameters:
+//   - GitCommit: The git commit the binary was built from.
+//   - GitDate: The git commit date the binary was built from.
+//
+// Returns:
+//   - *cli.App: The application with the api, rpc, migrate, token, reseal,
+//     seed and version commands.
+func NewCli(GitCommit string, GitDate string) *cli.App {
+	flags := flags2.Flags
+	issueFlags := append(append([]cli.Flag{}, flags...), flags2.BusinessIdFlag, flags2.ScopesFlag)
+	businessFlags := append(append([]cli.Flag{}, flags...), flags2.BusinessIdFlag)
+	tokenIdFlags := append(append([]cli.Flag{}, flags...), flags2.TokenIdFlag)
+	return &cli.App{
+		Version:              params.VersionWithCommit(GitCommit, GitDate),
+		Description:          "A wallet signature service with rpc and rest api server",
+		EnableBashCompletion: true,
+		Commands: []*cli.Command{
+			{
+				Name:   "api",
+				Flags:  flags,
+				Usage:  "Run rest api services",
+				Action: cliapp.LifecycleCmd(runRestApi),
+			},
+			{
+				Name:   "rpc",
+				Flags:  flags,
+				Usage:  "Run rpc services",
+				Action: cliapp.LifecycleCmd(runRpc),
+			},
+			{
+				Name:   "migrate",
+				Flags:  flags,
+				Usage:  "Run database migrations",
+				Action: runMigrations,
+				// The subcommands read the database flags given to migrate,
+				// as in `migrate --db-host ... status`.
+				Subcommands: []*cli.Command{
+					{
+						Name:   "up",
+						Usage:  "Apply the pending migrations",
+						Action: runMigrations,
+					},
+					{
+						Name:   "down",
+						Flags:  []cli.Flag{flags2.StepsFlag},
+						Usage:  "Revert the last applied migrations",
+						Action: runMigrateDown,
+					},
+					{
+						Name:   "redo",
+						Usage:  "Revert and re-apply the last applied migration",
+						Action: runMigrateRedo,
+					},
+					{
+						Name:   "status",
+						Usage:  "Show the applied and pending migrations",
+						Action: runMigrateStatus,
+					},
+				},
+			},
+			{
+				Name:  "token",
+				Usage: "Manage consumer tokens",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "issue",
+						Flags:  issueFlags,
+						Usage:  "Issue a consumer token bound to a business id",
+						Action: runIssueToken,
+					},
+					{
+						Name:   "revoke",
+						Flags:  tokenIdFlags,
+						Usage:  "Revoke a consumer token",
+						Action: runRevokeToken,
+					},
+					{
+						Name:   "list",
+						Flags:  businessFlags,
+						Usage:  "List the consumer tokens of a business id",
+						Action: runListTokens,
+					},
+				},
+			},
+			{
+				Name:   "reseal",
+				Flags:  flags,
+				Usage:  "Re-encrypt plaintext keys and keys sealed under a retired master key with the current master key",
+				Action: runReseal,
+			},
+			{
+				Name:  "seed",
+				Usage: "Back up and restore the HD wallet seeds",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "export",
+						Flags:  businessFlags,
+						Usage:  "Print the mnemonic of the HD wallet seed of a business id",
+						Action: runExportSeed,
+					},
+					{
+						Name:   "import",
+						Flags:  businessFlags,
+						Usage:  "Restore the HD wallet seed of a business id from a mnemonic read from stdin",
+						Action: runImportSeed,
+					},
+				},
+			},
+			{
+				Name:  "version",
+				Usage: "Show project version",
+				Action: func(ctx *cli.Context) error {
+					cli.ShowVersion(ctx)
+					return nil
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/opio"
+)
+
+var (
+	GitCommit = ""
+	GitDate   = ""
+)
+
+// main is the entry point of the go-signature binary.
+//
+// It installs a terminal logger, wraps the root context with an interrupt
+// blocker so that every subcommand shares the same signal handling, and runs
+// the cli application with the process arguments.
+func main() {
+	log.SetDefault(log.NewLogger(log.NewTerminalHandlerWithLevel(os.Stderr, log.LevelInfo, true)))
+	app := NewCli(GitCommit, GitDate)
+	ctx := opio.WithInterruptBlocker(context.Background())
+	if err := app.RunContext(ctx, os.Args); err != nil {
+		log.Error("Application failed", "err", err)
+		os.Exit(1)
+	}
+}
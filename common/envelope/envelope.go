@@ -0,0 +1,260 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PlaintextVersion marks rows that were written before envelope encryption
+// was introduced and still hold the private key in clear text.
+const PlaintextVersion uint32 = 0
+
+const masterKeyLen = 32
+
+var (
+	ErrMasterKeyMissing  = errors.New("master key is not configured")
+	ErrUnknownKeyVersion = errors.New("unknown master key version")
+)
+
+// Sealed is the result of envelope encryption: the payload encrypted with a
+// fresh data key, the data key encrypted with the master key, and the version
+// of the master key that wrapped it. Both byte fields carry their nonce as a
+// prefix.
+type Sealed struct {
+	Ciphertext []byte
+	DataKey    []byte
+	KeyVersion uint32
+}
+
+// Cipher performs AES-256-GCM envelope encryption. It seals under the current
+// master key and opens values sealed under any master key of its keyring, so
+// rows written before a key rotation stay readable until they are resealed.
+type Cipher struct {
+	keys    map[uint32]cipher.AEAD
+	version uint32
+}
+
+// NewCipher creates a Cipher from a 32 byte master key.
+//
+// Parameters:
+//   - masterKey: The raw 32 byte master key.
+//   - version: The version recorded alongside every row sealed by this cipher.
+//     Version 0 is reserved for plaintext rows.
+//
+// Returns:
+//   - *Cipher: The cipher if the key and version are valid.
+//   - error: An error if the key has the wrong length or the version is 0.
+func NewCipher(masterKey []byte, version uint32) (*Cipher, error) {
+	if len(masterKey) != masterKeyLen {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", masterKeyLen, len(masterKey))
+	}
+	if version == PlaintextVersion {
+		return nil, fmt.Errorf("master key version %d is reserved for plaintext keys", PlaintextVersion)
+	}
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{keys: map[uint32]cipher.AEAD{version: aead}, version: version}, nil
+}
+
+// AddKey adds a retired master key to the keyring. Values sealed under it can
+// be opened, but Seal keeps using the current master key, so the retired key
+// must have a lower version.
+//
+// Parameters:
+//   - masterKey: The raw 32 byte retired master key.
+//   - version: The version rows sealed under the retired key carry.
+//
+// Returns:
+//   - error: An error if the key has the wrong length or the version is 0,
+//     already in the keyring or not lower than the current version.
+func (c *Cipher) AddKey(masterKey []byte, version uint32) error {
+	if len(masterKey) != masterKeyLen {
+		return fmt.Errorf("master key %d must be %d bytes, got %d", version, masterKeyLen, len(masterKey))
+	}
+	if version == PlaintextVersion {
+		return fmt.Errorf("master key version %d is reserved for plaintext keys", PlaintextVersion)
+	}
+	if _, ok := c.keys[version]; ok {
+		return fmt.Errorf("duplicate master key version %d", version)
+	}
+	if version > c.version {
+		return fmt.Errorf("retired master key version %d is newer than the current version %d", version, c.version)
+	}
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return err
+	}
+	c.keys[version] = aead
+	return nil
+}
+
+// LoadCipher reads a hex encoded master key from either the given value or the
+// given file and creates a Cipher from it. Exactly one source must be set.
+//
+// Parameters:
+//   - hexKey: The hex encoded master key, usually taken from the environment.
+//   - keyFile: The path of a file holding the hex encoded master key.
+//   - version: The version of the master key.
+//
+// Returns:
+//   - *Cipher: The cipher built from the loaded master key.
+//   - error: ErrMasterKeyMissing if no source is set, or an error if the key
+//     cannot be read or decoded.
+func LoadCipher(hexKey, keyFile string, version uint32) (*Cipher, error) {
+	if hexKey != "" && keyFile != "" {
+		return nil, errors.New("master key and master key file are mutually exclusive")
+	}
+	if keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Error reading master key file: %s", keyFile))
+		}
+		hexKey = string(content)
+	}
+	hexKey = strings.TrimPrefix(strings.TrimSpace(hexKey), "0x")
+	if hexKey == "" {
+		return nil, ErrMasterKeyMissing
+	}
+	masterKey, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "master key is not valid hex")
+	}
+	return NewCipher(masterKey, version)
+}
+
+// LoadKeyring loads the current master key like LoadCipher and adds the
+// retired master keys listed in retiredFile, one "<version>:<hex key>" per
+// line. Blank lines and lines starting with # are skipped. An empty
+// retiredFile loads the current key only.
+//
+// Parameters:
+//   - hexKey: The hex encoded current master key.
+//   - keyFile: The path of a file holding the hex encoded current master key.
+//   - version: The version of the current master key.
+//   - retiredFile: The path of a file listing the retired master keys.
+//
+// Returns:
+//   - *Cipher: The cipher sealing under the current key and opening under all of them.
+//   - error: An error if a key cannot be read, decoded or added.
+func LoadKeyring(hexKey, keyFile string, version uint32, retiredFile string) (*Cipher, error) {
+	c, err := LoadCipher(hexKey, keyFile, version)
+	if err != nil || retiredFile == "" {
+		return c, err
+	}
+	content, err := os.ReadFile(retiredFile)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Error reading retired master key file: %s", retiredFile))
+	}
+	for n, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		versionText, keyText, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want <version>:<hex key>", retiredFile, n+1)
+		}
+		retiredVersion, err := strconv.ParseUint(strings.TrimSpace(versionText), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid version: %w", retiredFile, n+1, err)
+		}
+		masterKey, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(keyText), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: master key is not valid hex", retiredFile, n+1)
+		}
+		if err := c.AddKey(masterKey, uint32(retiredVersion)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", retiredFile, n+1, err)
+		}
+	}
+	return c, nil
+}
+
+// Version returns the version of the current master key, which Seal records
+// with every sealed value.
+func (c *Cipher) Version() uint32 {
+	return c.version
+}
+
+// Seal encrypts plaintext with a freshly generated data key and wraps the data
+// key with the master key. The additional data binds the ciphertext to its
+// owner, typically the row id, so sealed values cannot be swapped between rows.
+func (c *Cipher) Seal(plaintext, additionalData []byte) (*Sealed, error) {
+	dataKey := make([]byte, masterKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dataAEAD, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := seal(c.keys[c.version], dataKey, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealed{
+		Ciphertext: ciphertext,
+		DataKey:    wrappedKey,
+		KeyVersion: c.version,
+	}, nil
+}
+
+// Open unwraps the data key with the master key of the sealed value's version
+// and decrypts the payload. The additional data must match the value passed
+// to Seal.
+func (c *Cipher) Open(sealed *Sealed, additionalData []byte) ([]byte, error) {
+	master, ok := c.keys[sealed.KeyVersion]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, sealed.KeyVersion)
+	}
+	dataKey, err := open(master, sealed.DataKey, additionalData)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unwrap data key")
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataAEAD, sealed.Ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt payload")
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
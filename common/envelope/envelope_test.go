@@ -0,0 +1,142 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, version uint32) *Cipher {
+	masterKey := make([]byte, masterKeyLen)
+	if _, err := rand.Read(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCipher(masterKey, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSealOpen(t *testing.T) {
+	c := newTestCipher(t, 1)
+	plaintext := []byte("private key")
+	sealed, err := c.Seal(plaintext, []byte("row-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed.Ciphertext, plaintext) {
+		t.Fatal("ciphertext contains plaintext")
+	}
+	opened, err := c.Open(sealed, []byte("row-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Fatalf("got %q, want %q", opened, plaintext)
+	}
+	if _, err := c.Open(sealed, []byte("row-2")); err == nil {
+		t.Fatal("expected open with different additional data to fail")
+	}
+}
+
+func TestOpenRejectsOtherVersion(t *testing.T) {
+	sealed, err := newTestCipher(t, 1).Seal([]byte("private key"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = newTestCipher(t, 2).Open(sealed, nil)
+	if !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Fatalf("got %v, want ErrUnknownKeyVersion", err)
+	}
+}
+
+func TestLoadCipher(t *testing.T) {
+	hexKey := hex.EncodeToString(bytes.Repeat([]byte{0x01}, masterKeyLen))
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	if err := os.WriteFile(keyFile, []byte(hexKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCipher("", keyFile, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCipher("0x"+hexKey, "", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCipher("", "", 1); !errors.Is(err, ErrMasterKeyMissing) {
+		t.Fatalf("got %v, want ErrMasterKeyMissing", err)
+	}
+	if _, err := LoadCipher(hexKey, "", 0); err == nil {
+		t.Fatal("expected version 0 to be rejected")
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{0x01}, masterKeyLen)
+	old, err := NewCipher(oldKey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := old.Seal([]byte("private key"), []byte("row-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCipher(t, 2)
+	if err := c.AddKey(oldKey, 1); err != nil {
+		t.Fatal(err)
+	}
+	opened, err := c.Open(sealed, []byte("row-1"))
+	if err != nil || string(opened) != "private key" {
+		t.Fatalf("got %q, %v", opened, err)
+	}
+	resealed, err := c.Seal(opened, []byte("row-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resealed.KeyVersion != 2 {
+		t.Fatalf("sealed under version %d, want 2", resealed.KeyVersion)
+	}
+
+	for _, version := range []uint32{0, 1, 2, 3} {
+		if err := c.AddKey(oldKey, version); err == nil {
+			t.Errorf("version %d accepted", version)
+		}
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	hexKey := hex.EncodeToString(bytes.Repeat([]byte{0x03}, masterKeyLen))
+	oldKey := bytes.Repeat([]byte{0x01}, masterKeyLen)
+	retiredFile := filepath.Join(t.TempDir(), "retired.keys")
+	content := "# rotated out\n1:0x" + hex.EncodeToString(oldKey) + "\n\n2:" + hex.EncodeToString(bytes.Repeat([]byte{0x02}, masterKeyLen)) + "\n"
+	if err := os.WriteFile(retiredFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadKeyring(hexKey, "", 3, retiredFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := NewCipher(oldKey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := old.Seal([]byte("private key"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Open(sealed, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(retiredFile, []byte("not a key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyring(hexKey, "", 3, retiredFile); err == nil {
+		t.Fatal("malformed retired key file accepted")
+	}
+}
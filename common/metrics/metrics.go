@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/httputil"
+)
+
+const (
+	Namespace = "go_signature"
+	Path      = "/metrics"
+)
+
+// Registry holds every collector of the service. It is served by StartServer.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "rest",
+		Name:      "requests_total",
+		Help:      "Number of REST requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "rest",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of REST requests by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	grpcRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "Number of gRPC calls by method and status code.",
+	}, []string{"method", "code"})
+	grpcRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of gRPC calls by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	addressesGenerated = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "addresses_generated_total",
+		Help:      "Number of keys generated by chain, network and source.",
+	}, []string{"chain", "network", "source"})
+	signingOperations = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "signing_operations_total",
+		Help:      "Number of signing operations by kind and result.",
+	}, []string{"operation", "result"})
+	retryAttempts = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "retry_attempts_total",
+		Help:      "Number of failed attempts that were retried.",
+	})
+	replicaHealthy = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "db_replica",
+		Name:      "healthy",
+		Help:      "Whether reads go to the read replica (1) or fall back to the primary (0).",
+	})
+	replicaLag = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "db_replica",
+		Name:      "lag_seconds",
+		Help:      "Replication lag of the read replica at the last health check.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// RecordHTTPRequest records a served REST request under its chi route pattern.
+func RecordHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// RecordGRPCRequest records a finished gRPC call with its status code name.
+func RecordGRPCRequest(method, code string, duration time.Duration) {
+	grpcRequests.WithLabelValues(method, code).Inc()
+	grpcRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// RecordAddresses records n newly generated keys.
+func RecordAddresses(chain, network, source string, n int) {
+	addressesGenerated.WithLabelValues(chain, network, source).Add(float64(n))
+}
+
+// RecordSigning records a signing operation and whether it succeeded.
+func RecordSigning(operation string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	signingOperations.WithLabelValues(operation, result).Inc()
+}
+
+// RecordRetry records one failed attempt that is going to be retried.
+func RecordRetry() {
+	retryAttempts.Inc()
+}
+
+// RecordReplicaHealth records the result of a read replica health check.
+func RecordReplicaHealth(healthy bool, lag time.Duration) {
+	if healthy {
+		replicaHealthy.Set(1)
+	} else {
+		replicaHealthy.Set(0)
+	}
+	replicaLag.Set(lag.Seconds())
+}
+
+// RegisterDB exports the connection pool stats of db under the given name.
+// Registering the same name twice is not an error.
+func RegisterDB(name string, db *sql.DB) error {
+	err := Registry.Register(collectors.NewDBStatsCollector(db, name))
+	if are := (prometheus.AlreadyRegisteredError{}); errors.As(err, &are) {
+		return nil
+	}
+	return err
+}
+
+// StartServer serves the registry on Path at host:port.
+//
+// Parameters:
+//   - host: The host to listen on.
+//   - port: The port to listen on.
+//
+// Returns:
+//   - *httputil.HTTPServer: The running metrics server, stopped with its Stop method.
+//   - error: An error if the listener cannot be created.
+func StartServer(host string, port int) (*httputil.HTTPServer, error) {
+	mux := http.NewServeMux()
+	mux.Handle(Path, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	srv, err := httputil.StarHttpServer(addr, mux)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start metrics server on %s: %w", addr, err)
+	}
+	return srv, nil
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartServer(t *testing.T) {
+	srv, err := StartServer("127.0.0.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop(context.Background())
+
+	RecordHTTPRequest(http.MethodGet, "/api/v1/keys", http.StatusOK, time.Millisecond)
+	RecordSigning("message", nil)
+
+	resp, err := http.Get("http://" + srv.Addr().String() + Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`go_signature_rest_requests_total{method="GET",route="/api/v1/keys",status="200"} 1`,
+		`go_signature_signing_operations_total{operation="message",result="success"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
 )
 
 type ErrFailedPermanently struct {
@@ -49,6 +51,7 @@ func Do[T any](ctx context.Context, maxAttempts int, strategy Strategy, op func(
 			return ret, nil
 		}
 		if i != maxAttempts-1 {
+			metrics.RecordRetry()
 			time.Sleep(strategy.Duration(i))
 		}
 	}
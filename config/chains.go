@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChainConfig describes one supported chain in the config file.
+type ChainConfig struct {
+	Name           string          `yaml:"name"`
+	Aliases        []string        `yaml:"aliases"`
+	Curve          string          `yaml:"curve"`
+	AddressFormats []string        `yaml:"address_formats"`
+	DerivationPath string          `yaml:"derivation_path"`
+	Networks       []NetworkConfig `yaml:"networks"`
+}
+
+// NetworkConfig describes one network of a chain in the config file.
+type NetworkConfig struct {
+	Name           string   `yaml:"name"`
+	Aliases        []string `yaml:"aliases"`
+	ChainId        uint64   `yaml:"chain_id"`
+	Params         string   `yaml:"params"`
+	DerivationPath string   `yaml:"derivation_path"`
+}
+
+type fileConfig struct {
+	Chains []ChainConfig `yaml:"chains"`
+}
+
+// loadFile reads the chain registry from the yaml config file at path.
+//
+// Parameters:
+//   - path: The path of the config file.
+//   - required: Whether a missing file is an error. When it is not, a missing
+//     file yields no chains and the built-in registry is used.
+//
+// Returns:
+//   - []ChainConfig: The chains listed in the file.
+//   - error: An error if the file cannot be read or parsed.
+func loadFile(path string, required bool) ([]ChainConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var file fileConfig
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return file.Chains, nil
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/qiaopengjun5162/go-rpc-service/flags"
 	"github.com/urfave/cli/v2"
 )
@@ -11,14 +13,74 @@ type Config struct {
 	RpcServer     ServerConfig
 	HTTPServer    ServerConfig
 	MetricsServer ServerConfig
+	// RpcMetricsServer serves the metrics of the rpc service, MetricsServer
+	// those of the rest api.
+	RpcMetricsServer ServerConfig
+	MasterKey        MasterKeyConfig
+	AddressPool      AddressPoolConfig
+	Chains           []ChainConfig
 }
 
 type DBConfig struct {
+	// Driver selects the storage backend, postgres or sqlite. Path is the
+	// sqlite database file, or ":memory:"; the remaining settings only apply
+	// to postgres.
+	Driver   string
+	Path     string
 	Host     string
 	Port     int
 	Name     string
 	User     string
 	Password string
+	Replica  ReplicaConfig
+	TLS      DBTLSConfig
+	Pool     DBPoolConfig
+	// StatementTimeout aborts statements running longer than it. Zero
+	// leaves the server default.
+	StatementTimeout time.Duration
+}
+
+// DBTLSConfig configures TLS to the primary and the read replica. The
+// certificate and key are only needed for client certificate authentication.
+type DBTLSConfig struct {
+	SSLMode  string
+	RootCert string
+	Cert     string
+	Key      string
+}
+
+// DBPoolConfig configures the connection pools of the primary and the read
+// replica. Zero values keep the database/sql defaults.
+type DBPoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// ReplicaConfig configures the optional read replica. The replica is disabled
+// when Host is empty; an empty port, name or user defaults to the primary's.
+type ReplicaConfig struct {
+	Host          string
+	Port          int
+	Name          string
+	User          string
+	Password      string
+	MaxLag        time.Duration
+	CheckInterval time.Duration
+}
+
+type MasterKeyConfig struct {
+	Key     string
+	File    string
+	Version uint32
+	// RetiredFile lists the master keys that were rotated out.
+	RetiredFile string
+}
+
+type AddressPoolConfig struct {
+	Size           int
+	LowWater       int
+	RefillInterval time.Duration
 }
 
 type ServerConfig struct {
@@ -28,24 +90,60 @@ type ServerConfig struct {
 
 // NewConfig creates a new instance of Config from the given CLI context.
 //
-// It extracts settings for database, RPC server, HTTP server, and metrics server
-// from the provided CLI context flags. These settings include host, port, name,
-// user, and password for the database, as well as host and port for the servers.
+// It extracts settings for database, its driver, its TLS and connection pool and its
+// optional read replica, RPC server, HTTP server, the metrics servers of the rest
+// api and the rpc service and master key from the provided CLI context flags. These settings include host, port,
+// name, user, and password for the database, host and port for the servers, the
+// source and version of the master key used to encrypt private keys, the file of
+// retired master keys, and the size, low-water mark and refill interval of the
+// address pool.
+//
+// The supported chains are read from the yaml config file. A missing file is
+// only an error when its path was set explicitly; otherwise the built-in
+// chains are used.
 //
 // Parameters:
 //   - ctx: A cli.Context containing the CLI flag values.
 //
 // Returns:
 //   - Config: A Config instance populated with values extracted from the CLI context.
-func NewConfig(ctx *cli.Context) Config {
+//   - error: An error if the config file cannot be read or parsed.
+func NewConfig(ctx *cli.Context) (Config, error) {
+	chainList, err := loadFile(ctx.String(flags.ConfigFileFlag.Name), ctx.IsSet(flags.ConfigFileFlag.Name))
+	if err != nil {
+		return Config{}, err
+	}
 	return Config{
 		Migrations: ctx.String(flags.MigrationsFlag.Name),
 		Database: DBConfig{
+			Driver:   ctx.String(flags.DbDriverFlag.Name),
+			Path:     ctx.String(flags.DbPathFlag.Name),
 			Host:     ctx.String(flags.DbHostFlag.Name),
 			Port:     ctx.Int(flags.DbPortFlag.Name),
 			Name:     ctx.String(flags.DbNameFlag.Name),
 			User:     ctx.String(flags.DbUserFlag.Name),
 			Password: ctx.String(flags.DbPasswordFlag.Name),
+			Replica: ReplicaConfig{
+				Host:          ctx.String(flags.ReplicaDbHostFlag.Name),
+				Port:          ctx.Int(flags.ReplicaDbPortFlag.Name),
+				Name:          ctx.String(flags.ReplicaDbNameFlag.Name),
+				User:          ctx.String(flags.ReplicaDbUserFlag.Name),
+				Password:      ctx.String(flags.ReplicaDbPasswordFlag.Name),
+				MaxLag:        ctx.Duration(flags.ReplicaMaxLagFlag.Name),
+				CheckInterval: ctx.Duration(flags.ReplicaCheckIntervalFlag.Name),
+			},
+			TLS: DBTLSConfig{
+				SSLMode:  ctx.String(flags.DbSSLModeFlag.Name),
+				RootCert: ctx.String(flags.DbSSLRootCertFlag.Name),
+				Cert:     ctx.String(flags.DbSSLCertFlag.Name),
+				Key:      ctx.String(flags.DbSSLKeyFlag.Name),
+			},
+			Pool: DBPoolConfig{
+				MaxOpenConns:    ctx.Int(flags.DbMaxOpenConnsFlag.Name),
+				MaxIdleConns:    ctx.Int(flags.DbMaxIdleConnsFlag.Name),
+				ConnMaxLifetime: ctx.Duration(flags.DbConnMaxLifetimeFlag.Name),
+			},
+			StatementTimeout: ctx.Duration(flags.DbStatementTimeoutFlag.Name),
 		},
 		RpcServer: ServerConfig{
 			Host: ctx.String(flags.RpcHostFlag.Name),
@@ -59,5 +157,21 @@ func NewConfig(ctx *cli.Context) Config {
 			Host: ctx.String(flags.MetricsHostFlag.Name),
 			Port: ctx.Int(flags.MetricsPortFlag.Name),
 		},
-	}
+		RpcMetricsServer: ServerConfig{
+			Host: ctx.String(flags.MetricsHostFlag.Name),
+			Port: ctx.Int(flags.RpcMetricsPortFlag.Name),
+		},
+		MasterKey: MasterKeyConfig{
+			Key:         ctx.String(flags.MasterKeyFlag.Name),
+			File:        ctx.String(flags.MasterKeyFileFlag.Name),
+			Version:     uint32(ctx.Uint(flags.MasterKeyVersionFlag.Name)),
+			RetiredFile: ctx.String(flags.MasterKeyRetiredFileFlag.Name),
+		},
+		AddressPool: AddressPoolConfig{
+			Size:           ctx.Int(flags.AddressPoolSizeFlag.Name),
+			LowWater:       ctx.Int(flags.AddressPoolLowWaterFlag.Name),
+			RefillInterval: ctx.Duration(flags.AddressPoolRefillIntervalFlag.Name),
+		},
+		Chains: chainList,
+	}, nil
 }
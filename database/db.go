@@ -2,44 +2,171 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 
-	"github.com/pkg/errors"
+	"github.com/ethereum/go-ethereum/log"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"github.com/qiaopengjun5162/go-rpc-service/common/retry"
 	"github.com/qiaopengjun5162/go-rpc-service/config"
+	"github.com/qiaopengjun5162/go-rpc-service/migrations"
+)
+
+// CreateBatchSize is the number of rows gorm inserts per statement.
+const CreateBatchSize = 3_000
+
+// DriverPostgres and DriverSQLite are the supported storage backends. They
+// match the gorm dialect names and the folders of the migrations.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
 type DB struct {
-	gorm *gorm.DB
+	gorm    *gorm.DB
+	replica *replica
+	// inMemory is set for an in-memory sqlite database, which starts empty.
+	inMemory bool
 
+	// Keys reads and writes keys on the primary.
 	Keys KeysDB
+	// KeysView reads keys from the read replica while it is healthy, and from
+	// the primary otherwise or when no replica is configured. The replica may
+	// lag behind the primary.
+	KeysView KeysView
+	Seeds    SeedsDB
+	Tokens   TokensDB
 }
 
-func NewDB(ctx context.Context, dbConf config.DBConfig) (*DB, error) {
-	dsn := fmt.Sprintf("host=%s dbname=%s sslmode=disable", dbConf.Host, dbConf.Name)
+// sslModes are the sslmode values accepted by libpq and pgx.
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// dsn builds the keyword/value connection string of dbConf. Values are
+// quoted, so passwords and paths may contain spaces and quotes.
+//
+// Parameters:
+//   - dbConf: The database configuration.
+//
+// Returns:
+//   - string: The connection string.
+//   - error: An error if the TLS settings are invalid.
+func dsn(dbConf config.DBConfig) (string, error) {
+	tls := dbConf.TLS
+	sslMode := tls.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	if !sslModes[sslMode] {
+		return "", fmt.Errorf("invalid sslmode %q", sslMode)
+	}
+	if (tls.Cert == "") != (tls.Key == "") {
+		return "", errors.New("the client certificate and key must be set together")
+	}
+	params := [][2]string{
+		{"host", dbConf.Host},
+		{"dbname", dbConf.Name},
+		{"sslmode", sslMode},
+	}
 	if dbConf.Port != 0 {
-		dsn += fmt.Sprintf(" port=%d", dbConf.Port)
+		params = append(params, [2]string{"port", strconv.Itoa(dbConf.Port)})
 	}
 	if dbConf.User != "" {
-		dsn += fmt.Sprintf(" user=%s", dbConf.User)
+		params = append(params, [2]string{"user", dbConf.User})
 	}
 	if dbConf.Password != "" {
-		dsn += fmt.Sprintf(" password=%s", dbConf.Password)
+		params = append(params, [2]string{"password", dbConf.Password})
+	}
+	if tls.RootCert != "" {
+		params = append(params, [2]string{"sslrootcert", tls.RootCert})
+	}
+	if tls.Cert != "" {
+		params = append(params, [2]string{"sslcert", tls.Cert}, [2]string{"sslkey", tls.Key})
+	}
+	if dbConf.StatementTimeout > 0 {
+		params = append(params, [2]string{"statement_timeout", strconv.FormatInt(dbConf.StatementTimeout.Milliseconds(), 10)})
+	}
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(p[1])
+		parts = append(parts, fmt.Sprintf("%s='%s'", p[0], value))
 	}
+	return strings.Join(parts, " "), nil
+}
 
+// configurePool applies the connection pool settings to the pool behind
+// gormDB. Zero values keep the database/sql defaults.
+func configurePool(gormDB *gorm.DB, pool config.DBPoolConfig) error {
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return err
+	}
+	if pool.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	return nil
+}
+
+// NewDB opens the storage backend selected by dbConf.Driver.
+//
+// For postgres it connects to the primary database and, when dbConf.Replica
+// has a host, to the read replica serving KeysView. Both use the TLS, pool
+// and statement timeout settings of dbConf. For sqlite it opens the database
+// file at dbConf.Path, or an in-memory database for ":memory:".
+//
+// Parameters:
+//   - ctx: A context.Context that controls the connection timeout.
+//   - dbConf: The database configuration.
+//
+// Returns:
+//   - *DB: The database.
+//   - error: An error if the settings are invalid or the primary cannot be reached.
+func NewDB(ctx context.Context, dbConf config.DBConfig) (*DB, error) {
 	gormConfig := gorm.Config{
 		SkipDefaultTransaction: true,
-		CreateBatchSize:        3_000,
+		CreateBatchSize:        CreateBatchSize,
+	}
+	switch dbConf.Driver {
+	case "", DriverPostgres:
+		return openPostgres(dbConf, gormConfig)
+	case DriverSQLite:
+		return openSQLite(dbConf, gormConfig)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q, want %s or %s", dbConf.Driver, DriverPostgres, DriverSQLite)
+	}
+}
+
+func openPostgres(dbConf config.DBConfig, gormConfig gorm.Config) (*DB, error) {
+	if dbConf.Host == "" || dbConf.Name == "" {
+		return nil, errors.New("the postgres driver needs the master database host and name")
+	}
+	primaryDSN, err := dsn(dbConf)
+	if err != nil {
+		return nil, err
 	}
 
 	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
 	gorm, err := retry.Do[*gorm.DB](context.Background(), 10, retryStrategy, func() (*gorm.DB, error) {
-		gorm, err := gorm.Open(postgres.Open(dsn), &gormConfig)
+		gorm, err := gorm.Open(postgres.Open(primaryDSN), &gormConfig)
 		if err != nil {
 			return nil, fmt.Errorf("failed to connect to database: %w", err)
 		}
@@ -48,49 +175,140 @@ func NewDB(ctx context.Context, dbConf config.DBConfig) (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	db := &DB{
-		gorm: gorm,
-		Keys: NewKeysDB(gorm),
+	db := newDB(gorm)
+	if err := configurePool(gorm, dbConf.Pool); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to configure pool: %w", err), db.Close())
+	}
+	log.Info("connected to database", "host", dbConf.Host, "sslmode", dbConf.TLS.SSLMode,
+		"max_open_conns", dbConf.Pool.MaxOpenConns, "max_idle_conns", dbConf.Pool.MaxIdleConns,
+		"conn_max_lifetime", dbConf.Pool.ConnMaxLifetime, "statement_timeout", dbConf.StatementTimeout)
+	if dbConf.Replica.Host != "" {
+		r, err := openReplica(dbConf, gormConfig)
+		if err != nil {
+			return nil, errors.Join(err, db.Close())
+		}
+		db.replica = r
+		db.KeysView = &replicaKeysView{primary: db.Keys, replica: NewKeysDB(r.gorm), health: r}
 	}
 	return db, nil
 }
 
+func newDB(gorm *gorm.DB) *DB {
+	db := &DB{
+		gorm:   gorm,
+		Keys:   NewKeysDB(gorm),
+		Seeds:  NewSeedsDB(gorm),
+		Tokens: NewTokensDB(gorm),
+	}
+	db.KeysView = db.Keys
+	return db
+}
+
+// Driver returns the storage backend of db, DriverPostgres or DriverSQLite.
+func (db *DB) Driver() string {
+	return db.gorm.Dialector.Name()
+}
+
+// Transaction runs fn in a transaction on the primary. Every read of the
+// transaction, including KeysView, goes to the primary.
 func (db *DB) Transaction(fn func(db *DB) error) error {
 	return db.gorm.Transaction(func(tx *gorm.DB) error {
-		txDB := &DB{
-			gorm: tx,
-			Keys: NewKeysDB(tx),
-		}
-		return fn(txDB)
+		return fn(newDB(tx))
 	})
 }
 
+// SQLDB returns the connection pool behind db.
+func (db *DB) SQLDB() (*sql.DB, error) {
+	return db.gorm.DB()
+}
+
+// Close stops the replica health checks and closes the replica and primary
+// connection pools.
 func (db *DB) Close() error {
+	var result error
+	if db.replica != nil {
+		if err := db.replica.close(); err != nil {
+			result = errors.Join(result, fmt.Errorf("failed to close replica: %w", err))
+		}
+	}
 	sql, err := db.gorm.DB()
 	if err != nil {
-		return err
+		return errors.Join(result, err)
+	}
+	return errors.Join(result, sql.Close())
+}
+
+// MigrationsFS returns the migrations of the override folder, or the migrations
+// embedded in the binary when no folder is given.
+//
+// Parameters:
+//   - migrationsFolder: The folder holding one folder of <version>_<name>.sql
+//     files per driver, or an empty string for the embedded migrations.
+//
+// Returns:
+//   - fs.FS: The file system holding the migration folders.
+func MigrationsFS(migrationsFolder string) fs.FS {
+	if migrationsFolder == "" {
+		return migrations.FS
 	}
-	return sql.Close()
+	return os.DirFS(migrationsFolder)
 }
 
+// ExecuteSQLMigration applies the pending migrations, read from the override
+// folder or the embedded migrations, see MigrationsFS.
+//
+// Parameters:
+//   - migrationsFolder: The folder holding one folder of <version>_<name>.sql
+//     files per driver, or an empty string for the embedded migrations.
+//
+// Returns:
+//   - error: An error if an applied migration was modified or a migration fails.
 func (db *DB) ExecuteSQLMigration(migrationsFolder string) error {
-	err := filepath.Walk(migrationsFolder, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return errors.Wrap(err, fmt.Sprintf("Failed to process migration file: %s", path))
-		}
-		if info.IsDir() {
-			return nil
-		}
-		fileContent, readErr := os.ReadFile(path)
-		if readErr != nil {
-			return errors.Wrap(readErr, fmt.Sprintf("Error reading SQL file: %s", path))
-		}
+	migrator, err := db.NewMigrator(MigrationsFS(migrationsFolder))
+	if err != nil {
+		return err
+	}
+	count, err := migrator.Up()
+	if err != nil {
+		return err
+	}
+	log.Info("migrations up to date", "applied", count)
+	return nil
+}
 
-		execErr := db.gorm.Exec(string(fileContent)).Error
-		if execErr != nil {
-			return errors.Wrap(execErr, fmt.Sprintf("Error executing SQL script: %s", path))
-		}
-		return nil
-	})
-	return err
+// VerifyMigrations refuses a database whose applied migrations no longer match
+// the migration files, read from the override folder or the embedded
+// migrations, see MigrationsFS.
+//
+// Parameters:
+//   - migrationsFolder: The folder holding one folder of <version>_<name>.sql
+//     files per driver, or an empty string for the embedded migrations.
+//
+// Returns:
+//   - error: An error wrapping ErrMigrationModified if an applied migration was
+//     edited, or an error if the migrations cannot be loaded or queried.
+func (db *DB) VerifyMigrations(migrationsFolder string) error {
+	migrator, err := db.NewMigrator(MigrationsFS(migrationsFolder))
+	if err != nil {
+		return err
+	}
+	return migrator.Verify()
+}
+
+// PrepareSchema readies the schema for the services at start-up. The applied
+// migrations of a persistent database are verified, see VerifyMigrations. An
+// in-memory sqlite database starts empty, so every migration is applied
+// instead, see ExecuteSQLMigration.
+//
+// Parameters:
+//   - migrationsFolder: The folder holding one folder of <version>_<name>.sql
+//     files per driver, or an empty string for the embedded migrations.
+//
+// Returns:
+//   - error: An error if an applied migration was edited or a migration fails.
+func (db *DB) PrepareSchema(migrationsFolder string) error {
+	if db.inMemory {
+		return db.ExecuteSQLMigration(migrationsFolder)
+	}
+	return db.VerifyMigrations(migrationsFolder)
 }
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+)
+
+func TestDSN(t *testing.T) {
+	s, err := dsn(config.DBConfig{
+		Host:     "db.example.com",
+		Port:     5432,
+		Name:     "signature",
+		User:     "signer",
+		Password: `it's a \secret`,
+		TLS: config.DBTLSConfig{
+			SSLMode: "require",
+		},
+		StatementTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := pgconn.ParseConfig(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	if conf.Host != "db.example.com" || conf.Port != 5432 || conf.Database != "signature" || conf.User != "signer" {
+		t.Fatalf("unexpected config %+v", conf)
+	}
+	if conf.Password != `it's a \secret` {
+		t.Fatalf("password not quoted: %q", conf.Password)
+	}
+	if conf.TLSConfig == nil {
+		t.Fatal("require did not enable TLS")
+	}
+	if conf.RuntimeParams["statement_timeout"] != "5000" {
+		t.Fatalf("got statement_timeout %q", conf.RuntimeParams["statement_timeout"])
+	}
+}
+
+func TestDSNClientCertificate(t *testing.T) {
+	s, err := dsn(config.DBConfig{
+		Host: "db",
+		TLS: config.DBTLSConfig{
+			SSLMode:  "verify-full",
+			RootCert: "/etc/ssl/ca.pem",
+			Cert:     "/etc/ssl/client.pem",
+			Key:      "/etc/ssl/client.key",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"sslmode='verify-full'", "sslrootcert='/etc/ssl/ca.pem'", "sslcert='/etc/ssl/client.pem'", "sslkey='/etc/ssl/client.key'"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("%s missing %s", s, want)
+		}
+	}
+}
+
+func TestDSNInvalid(t *testing.T) {
+	for name, tls := range map[string]config.DBTLSConfig{
+		"unknown sslmode": {SSLMode: "on"},
+		"cert only":       {SSLMode: "require", Cert: "/etc/ssl/client.pem"},
+	} {
+		if _, err := dsn(config.DBConfig{Host: "db", TLS: tls}); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -1,26 +1,69 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+const (
+	DefaultPageSize uint64 = 20
+	MaxPageSize     uint64 = 1000
+	// MaxPage bounds the page number so that the row offset of the last page
+	// fits in an int on every platform.
+	MaxPage uint64 = 1_000_000
+)
+
+var (
+	ErrInvalidPage     = errors.New("invalid page")
+	ErrInvalidPageSize = errors.New("invalid page_size")
+)
+
+// Keys is a row of the keys table.
+//
+// PrivateKey holds the hex encoded private key sealed with a per-row data key,
+// and DataKey the hex encoded data key wrapped by the master key identified by
+// KeyVersion. Rows with KeyVersion 0 predate encryption and hold the private
+// key in clear text. Address, Chain, Network and AddressType are empty for rows
+// written before they were stored; AddressType is only set for Bitcoin keys.
 type Keys struct {
-	GUID       uuid.UUID `gorm:"primaryKey" json:"guid"`
-	BusinessId string    `json:"business_id"`
-	PrivateKey string    `json:"private_key"`
-	PublicKey  string    `json:"public_key"`
-	Timestamp  uint64
+	GUID        uuid.UUID `gorm:"primaryKey" json:"guid"`
+	BusinessId  string    `json:"business_id"`
+	PrivateKey  string    `json:"private_key"`
+	DataKey     string    `json:"data_key"`
+	KeyVersion  uint32    `json:"key_version"`
+	PublicKey   string    `json:"public_key"`
+	Address     string    `json:"address"`
+	Chain       string    `json:"chain"`
+	Network     string    `json:"network"`
+	AddressType string    `json:"address_type"`
+	// DerivationPath and DerivationIndex are set for keys derived from the
+	// business seed and empty for randomly generated keys.
+	DerivationPath  string  `json:"derivation_path"`
+	DerivationIndex *uint32 `json:"derivation_index"`
+	// Pooled marks a pre-generated key that has not been assigned to a
+	// business yet. Its business id is empty until it is assigned.
+	Pooled    bool `json:"pooled"`
+	Timestamp uint64
 }
 
 type KeysView interface {
-	QueryKeysByBusId(string, uint64, uint64) ([]Keys, error)
+	QueryKeysByBusId(string, uint64, uint64) ([]Keys, int64, error)
+	QueryKeyByPublicKey(string) (*Keys, error)
+	QueryKeyByAddress(string) (*Keys, error)
 }
 
 type KeysDB interface {
 	KeysView
 
 	StoreKeys([]Keys, uint64) error
+	QueryMaxDerivationIndex(string, string) (*uint32, error)
+	CountPooledKeys(string, string, string) (int64, error)
+	AssignPooledKey(string, string, string, string, uint64) (*Keys, error)
+	QueryKeysNotSealedWith(uint32, int) ([]Keys, error)
+	UpdateSealedKey(*Keys, uint32) (bool, error)
 }
 
 type addressesDB struct {
@@ -36,6 +79,178 @@ func (db *addressesDB) StoreKeys(keyList []Keys, keyLengthe uint64) error {
 	return result.Error
 }
 
-func (db *addressesDB) QueryKeysByBusId(busId string, page, pageSize uint64) ([]Keys, error) {
-	panic("implement me")
+// ValidatePagination rejects page numbers above MaxPage and page sizes above
+// MaxPageSize. Zero values are accepted and stand for the defaults applied by
+// NormalizePagination.
+func ValidatePagination(page, pageSize uint64) error {
+	if page > MaxPage {
+		return ErrInvalidPage
+	}
+	if pageSize > MaxPageSize {
+		return ErrInvalidPageSize
+	}
+	return nil
+}
+
+// NormalizePagination applies the paging defaults used by the keys queries.
+//
+// Pages start at 1, a zero page size falls back to DefaultPageSize and page
+// sizes above MaxPageSize are capped.
+func NormalizePagination(page, pageSize uint64) (uint64, uint64) {
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	return page, pageSize
+}
+
+// QueryKeysByBusId returns one page of the keys issued to the given business id
+// together with the total number of keys for that business id.
+//
+// Rows are ordered by timestamp and then guid so that pages are stable while
+// new keys are being appended. Paging follows NormalizePagination; pages
+// rejected by ValidatePagination fail with its error.
+func (db *addressesDB) QueryKeysByBusId(busId string, page, pageSize uint64) ([]Keys, int64, error) {
+	if err := ValidatePagination(page, pageSize); err != nil {
+		return nil, 0, err
+	}
+	page, pageSize = NormalizePagination(page, pageSize)
+
+	var total int64
+	err := db.gorm.Model(&Keys{}).Where("business_id = ?", busId).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var keyList []Keys
+	err = db.gorm.Where("business_id = ?", busId).
+		Order("timestamp ASC").
+		Order("guid ASC").
+		Offset(int((page - 1) * pageSize)).
+		Limit(int(pageSize)).
+		Find(&keyList).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return keyList, total, nil
+}
+
+// QueryKeyByPublicKey returns the key with the given hex encoded public key,
+// or nil if no such key has been issued.
+func (db *addressesDB) QueryKeyByPublicKey(publicKey string) (*Keys, error) {
+	var key Keys
+	result := db.gorm.Where("public_key = ?", publicKey).Take(&key)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &key, nil
+}
+
+// QueryKeyByAddress returns the key that was issued with the given address,
+// or nil if no such key has been issued.
+func (db *addressesDB) QueryKeyByAddress(address string) (*Keys, error) {
+	var key Keys
+	result := db.gorm.Where("address = ?", address).Take(&key)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &key, nil
+}
+
+// QueryMaxDerivationIndex returns the highest derivation index used by a
+// business below a derivation path, or nil if no key has been derived there
+// yet. Chains and networks that share a path share its indexes, so the same
+// index never yields the same key twice.
+func (db *addressesDB) QueryMaxDerivationIndex(busId, basePath string) (*uint32, error) {
+	var maxIndex sql.NullInt64
+	err := db.gorm.Model(&Keys{}).
+		Where("business_id = ? AND derivation_path LIKE ?", busId, basePath+"/%").
+		Select("MAX(derivation_index)").
+		Row().
+		Scan(&maxIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !maxIndex.Valid {
+		return nil, nil
+	}
+	index := uint32(maxIndex.Int64)
+	return &index, nil
+}
+
+// CountPooledKeys returns the number of unassigned pool keys for a chain,
+// network and address type.
+func (db *addressesDB) CountPooledKeys(chain, network, addressType string) (int64, error) {
+	var count int64
+	err := db.gorm.Model(&Keys{}).
+		Where("pooled AND chain = ? AND network = ? AND address_type = ?", chain, network, addressType).
+		Count(&count).Error
+	return count, err
+}
+
+// AssignPooledKey atomically takes one unassigned pool key for a chain,
+// network and address type and assigns it to the business id. It returns nil
+// if the pool is empty.
+//
+// Rows locked by a concurrent assignment are skipped, so concurrent callers
+// never receive the same key and never wait on each other. sqlite has no row
+// locks; it serializes writers, which gives the same guarantee.
+func (db *addressesDB) AssignPooledKey(busId, chain, network, addressType string, timestamp uint64) (*Keys, error) {
+	lock := "FOR UPDATE SKIP LOCKED"
+	if db.gorm.Dialector.Name() == DriverSQLite {
+		lock = ""
+	}
+	var keyList []Keys
+	err := db.gorm.Raw(`UPDATE keys SET business_id = ?, pooled = FALSE, timestamp = ?
+		WHERE guid = (
+			SELECT guid FROM keys
+			WHERE pooled AND chain = ? AND network = ? AND address_type = ?
+			LIMIT 1 `+lock+`
+		)
+		RETURNING *`, busId, timestamp, chain, network, addressType).
+		Scan(&keyList).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(keyList) == 0 {
+		return nil, nil
+	}
+	return &keyList[0], nil
+}
+
+// QueryKeysNotSealedWith returns up to limit keys whose private key is not
+// sealed under the given master key version, including plaintext rows, ordered
+// by guid.
+func (db *addressesDB) QueryKeysNotSealedWith(keyVersion uint32, limit int) ([]Keys, error) {
+	var keyList []Keys
+	err := db.gorm.Where("key_version <> ?", keyVersion).
+		Order("guid ASC").
+		Limit(limit).
+		Find(&keyList).Error
+	return keyList, err
+}
+
+// UpdateSealedKey stores the resealed private key, data key and key version
+// of key, provided the row is still sealed under fromVersion. It reports
+// whether the row was updated.
+func (db *addressesDB) UpdateSealedKey(key *Keys, fromVersion uint32) (bool, error) {
+	result := db.gorm.Model(&Keys{}).
+		Where("guid = ? AND key_version = ?", key.GUID, fromVersion).
+		Updates(map[string]any{
+			"private_key": key.PrivateKey,
+			"data_key":    key.DataKey,
+			"key_version": key.KeyVersion,
+		})
+	return result.RowsAffected == 1, result.Error
 }
@@ -0,0 +1,410 @@
+package database
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// MigrationsTable records the version and checksum of every applied migration.
+const MigrationsTable = "schema_migrations"
+
+// DownMarker starts the section of a migration file that reverts it.
+const DownMarker = "-- +down"
+
+var (
+	ErrMigrationModified     = errors.New("applied migration has been modified")
+	ErrMigrationIrreversible = errors.New("migration has no down section")
+	ErrNoAppliedMigration    = errors.New("no applied migration")
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+
+// Migration is one versioned SQL migration file. Files are named
+// <version>_<name>.sql and applied in numeric version order. The statements
+// after a DownMarker line revert the migration.
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus is the state of one migration in the database.
+type MigrationStatus struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt uint64
+	// Modified is set when the file of an applied migration no longer matches
+	// the recorded checksum.
+	Modified bool
+	// Missing is set when an applied migration has no file anymore.
+	Missing bool
+}
+
+type appliedMigration struct {
+	Version   uint64 `gorm:"primaryKey"`
+	Name      string
+	Checksum  string
+	AppliedAt uint64
+}
+
+func (appliedMigration) TableName() string {
+	return MigrationsTable
+}
+
+// LoadMigrations reads the migration files at the root of fsys, ordered by
+// version. Files without the .sql extension are ignored.
+//
+// Parameters:
+//   - fsys: The file system holding the migration files.
+//
+// Returns:
+//   - []Migration: The migrations ordered by version.
+//   - error: An error if a file cannot be read, is misnamed or reuses a version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migrations")
+	}
+	var migrations []Migration
+	versions := make(map[uint64]string)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Error reading SQL file: %s", entry.Name()))
+		}
+		migration, err := parseMigration(entry.Name(), content)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := versions[migration.Version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), migration.Version)
+		}
+		versions[migration.Version] = entry.Name()
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func parseMigration(file string, content []byte) (Migration, error) {
+	match := migrationFileName.FindStringSubmatch(file)
+	if match == nil {
+		return Migration{}, fmt.Errorf("invalid migration file name %s, want <version>_<name>.sql", file)
+	}
+	version, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return Migration{}, fmt.Errorf("invalid migration version in %s: %w", file, err)
+	}
+	var up, down strings.Builder
+	section := &up
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == DownMarker {
+			if section == &down {
+				return Migration{}, fmt.Errorf("migration %s has more than one down section", file)
+			}
+			section = &down
+			continue
+		}
+		section.WriteString(line)
+		section.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return Migration{}, errors.Wrap(err, fmt.Sprintf("Error reading SQL file: %s", file))
+	}
+	if strings.TrimSpace(up.String()) == "" {
+		return Migration{}, fmt.Errorf("migration %s has no statements", file)
+	}
+	sum := sha256.Sum256(content)
+	return Migration{
+		Version:  version,
+		Name:     match[2],
+		Up:       up.String(),
+		Down:     strings.TrimSpace(down.String()),
+		Checksum: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// Migrator applies and reverts versioned migrations. Every migration runs in
+// its own transaction together with the schema_migrations row recording it.
+type Migrator struct {
+	gorm       *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the migration files of the driver of db,
+// read from the folder of fsys named after the driver: postgres or sqlite.
+//
+// Parameters:
+//   - fsys: The file system holding one folder of migration files per driver.
+//
+// Returns:
+//   - *Migrator: The migrator.
+//   - error: An error if the driver's folder is missing, naming the expected
+//     layout when fsys holds the migration files directly as it used to, or
+//     if the migration files cannot be loaded.
+func (db *DB) NewMigrator(fsys fs.FS) (*Migrator, error) {
+	if info, err := fs.Stat(fsys, db.Driver()); err != nil || !info.IsDir() {
+		if flat, _ := fs.Glob(fsys, "*.sql"); len(flat) > 0 {
+			return nil, fmt.Errorf("the migrations folder holds %d .sql files directly, which is no longer supported: "+
+				"move the postgres migrations into a postgres/ subfolder and add a sqlite/ subfolder, as in the repository's migrations folder", len(flat))
+		}
+		return nil, fmt.Errorf("the migrations folder has no %s/ subfolder, it needs one subfolder per driver: postgres/ and sqlite/", db.Driver())
+	}
+	dialectFS, err := fs.Sub(fsys, db.Driver())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migrations")
+	}
+	migrations, err := LoadMigrations(dialectFS)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{gorm: db.gorm, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in version order after checking that no
+// applied migration has been modified.
+//
+// Returns:
+//   - int: The number of migrations applied.
+//   - error: An error if an applied migration was modified or a migration fails.
+//     The migrations applied before the failing one stay applied.
+func (m *Migrator) Up() (int, error) {
+	if err := m.ensureTable(); err != nil {
+		return 0, err
+	}
+	applied, err := m.verify()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.apply(migration); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the last steps applied migrations, newest first.
+//
+// Parameters:
+//   - steps: The number of migrations to revert.
+//
+// Returns:
+//   - int: The number of migrations reverted.
+//   - error: An error if an applied migration was modified or has no file or
+//     down section, or if reverting fails.
+func (m *Migrator) Down(steps int) (int, error) {
+	if err := m.ensureTable(); err != nil {
+		return 0, err
+	}
+	if _, err := m.verify(); err != nil {
+		return 0, err
+	}
+	var rows []appliedMigration
+	if err := m.gorm.Order("version DESC").Limit(steps).Find(&rows).Error; err != nil {
+		return 0, errors.Wrap(err, "failed to query applied migrations")
+	}
+	count := 0
+	for _, row := range rows {
+		migration, ok := m.migration(row.Version)
+		if !ok {
+			return count, fmt.Errorf("applied migration %d has no file", row.Version)
+		}
+		if err := m.revert(migration); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Redo reverts the last applied migration and applies it again.
+//
+// Returns:
+//   - error: ErrNoAppliedMigration if nothing is applied, or the error of
+//     reverting or applying the migration.
+func (m *Migrator) Redo() error {
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+	if _, err := m.verify(); err != nil {
+		return err
+	}
+	var row appliedMigration
+	result := m.gorm.Order("version DESC").Limit(1).Find(&row)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "failed to query applied migrations")
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoAppliedMigration
+	}
+	migration, ok := m.migration(row.Version)
+	if !ok {
+		return fmt.Errorf("applied migration %d has no file", row.Version)
+	}
+	if err := m.revert(migration); err != nil {
+		return err
+	}
+	return m.apply(migration)
+}
+
+// Status lists every migration file and every applied migration, ordered by
+// version.
+//
+// Returns:
+//   - []MigrationStatus: The state of every migration.
+//   - error: An error if the applied migrations cannot be queried.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var out []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.AppliedAt
+			status.Modified = row.Checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		out = append(out, status)
+	}
+	for _, row := range applied {
+		out = append(out, MigrationStatus{
+			Version:   row.Version,
+			Name:      row.Name,
+			Applied:   true,
+			AppliedAt: row.AppliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Verify checks that the file of every applied migration still matches the
+// checksum recorded when it was applied. The services call it at start-up so
+// an edited migration is noticed before it drifts from the schema.
+//
+// Returns:
+//   - error: ErrMigrationModified naming the modified migrations, or an error
+//     if the applied migrations cannot be queried.
+func (m *Migrator) Verify() error {
+	_, err := m.verify()
+	return err
+}
+
+func (m *Migrator) verify() (map[uint64]appliedMigration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var modified []string
+	for _, migration := range m.migrations {
+		row, ok := applied[migration.Version]
+		if ok && row.Checksum != migration.Checksum {
+			modified = append(modified, fmt.Sprintf("%d_%s", migration.Version, migration.Name))
+		}
+	}
+	if len(modified) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMigrationModified, strings.Join(modified, ", "))
+	}
+	return applied, nil
+}
+
+func (m *Migrator) applied() (map[uint64]appliedMigration, error) {
+	applied := make(map[uint64]appliedMigration)
+	if !m.gorm.Migrator().HasTable(MigrationsTable) {
+		return applied, nil
+	}
+	var rows []appliedMigration
+	if err := m.gorm.Find(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to query applied migrations")
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	err := m.gorm.Exec(`CREATE TABLE IF NOT EXISTS ` + MigrationsTable + ` (
+    version BIGINT PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    checksum VARCHAR NOT NULL,
+    applied_at BIGINT NOT NULL
+)`).Error
+	return errors.Wrap(err, "failed to create migrations table")
+}
+
+func (m *Migrator) apply(migration Migration) error {
+	err := m.gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Up).Error; err != nil {
+			return err
+		}
+		return tx.Create(&appliedMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum,
+			AppliedAt: uint64(time.Now().Unix()),
+		}).Error
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to apply migration %d_%s", migration.Version, migration.Name))
+	}
+	log.Info("applied migration", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+func (m *Migrator) revert(migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("%w: %d_%s", ErrMigrationIrreversible, migration.Version, migration.Name)
+	}
+	err := m.gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&appliedMigration{Version: migration.Version}).Error
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to revert migration %d_%s", migration.Version, migration.Name))
+	}
+	log.Info("reverted migration", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+func (m *Migrator) migration(version uint64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
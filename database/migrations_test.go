@@ -0,0 +1,148 @@
+package database
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"10_add_index.sql":   {Data: []byte("CREATE INDEX a ON t (a);\n\n-- +down\nDROP INDEX a;\n")},
+		"2_create_table.sql": {Data: []byte("CREATE TABLE t (a INT);\n")},
+		"README.md":          {Data: []byte("not a migration")},
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 2 || migrations[1].Version != 10 {
+		t.Fatalf("migrations not in numeric order: %+v", migrations)
+	}
+	if migrations[0].Name != "create_table" || migrations[0].Down != "" {
+		t.Fatalf("unexpected migration %+v", migrations[0])
+	}
+	if strings.Contains(migrations[1].Up, "DROP") || migrations[1].Down != "DROP INDEX a;" {
+		t.Fatalf("down section not split: up %q down %q", migrations[1].Up, migrations[1].Down)
+	}
+
+	fsys["10_add_index.sql"] = &fstest.MapFile{Data: []byte("CREATE INDEX b ON t (b);\n")}
+	edited, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited[1].Checksum == migrations[1].Checksum {
+		t.Fatal("checksum unchanged after editing the file")
+	}
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"duplicate version": {
+			"1_a.sql":  {Data: []byte("SELECT 1;")},
+			"01_b.sql": {Data: []byte("SELECT 1;")},
+		},
+		"no version": {"create.sql": {Data: []byte("SELECT 1;")}},
+		"no up":      {"1_a.sql": {Data: []byte("-- +down\nSELECT 1;")}},
+		"two downs":  {"1_a.sql": {Data: []byte("SELECT 1;\n-- +down\nSELECT 1;\n-- +down\n")}},
+	} {
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestShippedMigrations(t *testing.T) {
+	var drivers [][]Migration
+	for _, driver := range []string{DriverPostgres, DriverSQLite} {
+		embeddedFS, err := fs.Sub(MigrationsFS(""), driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		embedded, err := LoadMigrations(embeddedFS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		onDisk, err := LoadMigrations(MigrationsFS("../migrations/" + driver))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(embedded) == 0 || len(embedded) != len(onDisk) {
+			t.Fatalf("%s: embedded %d migrations, folder has %d", driver, len(embedded), len(onDisk))
+		}
+		for i, migration := range embedded {
+			if migration.Checksum != onDisk[i].Checksum {
+				t.Errorf("%s: embedded migration %d_%s differs from the folder", driver, migration.Version, migration.Name)
+			}
+			if migration.Down == "" {
+				t.Errorf("%s: migration %d_%s has no down section", driver, migration.Version, migration.Name)
+			}
+		}
+		drivers = append(drivers, embedded)
+	}
+	postgres, sqlite := drivers[0], drivers[1]
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations, sqlite %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("postgres migration %d_%s has no sqlite counterpart", postgres[i].Version, postgres[i].Name)
+		}
+	}
+}
+
+func TestNewMigratorLayout(t *testing.T) {
+	db := newTestDB(t)
+	for name, tt := range map[string]struct {
+		fsys fstest.MapFS
+		want string
+	}{
+		"flat":    {fstest.MapFS{"00001_create_schema.sql": {Data: []byte("SELECT 1;")}}, "postgres/ subfolder"},
+		"missing": {fstest.MapFS{"postgres/00001_create_schema.sql": {Data: []byte("SELECT 1;")}}, "no sqlite/ subfolder"},
+	} {
+		_, err := db.NewMigrator(tt.fsys)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error naming %q", name, err, tt.want)
+		}
+	}
+	if _, err := db.NewMigrator(fstest.MapFS{"sqlite/00001_create_schema.sql": {Data: []byte("SELECT 1;")}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	db := newTestDB(t)
+	migrator, err := db.NewMigrator(MigrationsFS(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(migrator.migrations) {
+		t.Fatalf("applied %d of %d migrations", count, len(migrator.migrations))
+	}
+	if err := migrator.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if reverted, err := migrator.Down(len(migrator.migrations)); err != nil || reverted != count {
+		t.Fatalf("reverted %d of %d migrations: %v", reverted, count, err)
+	}
+	if db.gorm.Migrator().HasTable("keys") {
+		t.Fatal("keys table left after reverting every migration")
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.Modified || status.Missing {
+			t.Errorf("unexpected status %+v", status)
+		}
+	}
+}
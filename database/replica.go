@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+)
+
+const (
+	defaultReplicaMaxLag        = 10 * time.Second
+	defaultReplicaCheckInterval = 5 * time.Second
+)
+
+// replicaLagQuery reports how far the replica's replay is behind the primary,
+// in seconds. A replica that has replayed everything it received is not
+// lagging, even if the primary has been idle for a while.
+const replicaLagQuery = `SELECT CASE
+    WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// replica is a read-only connection to a streaming replica of the primary.
+// Its health is checked in the background; reads fall back to the primary
+// while the replica is unreachable or lags more than maxLag behind.
+type replica struct {
+	gorm     *gorm.DB
+	maxLag   time.Duration
+	interval time.Duration
+	healthy  atomic.Bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// openReplica connects to the replica and starts checking its health. The
+// connection is made lazily, so an unreachable replica does not fail the
+// start-up; reads use the primary until the replica becomes healthy.
+//
+// Parameters:
+//   - dbConf: The primary's configuration. Replica settings that are left
+//     empty default to the primary's; TLS, pool and statement timeout
+//     settings are shared.
+//   - gormConfig: The gorm configuration of the primary.
+//
+// Returns:
+//   - *replica: The replica.
+//   - error: An error if the connection pool cannot be created.
+func openReplica(dbConf config.DBConfig, gormConfig gorm.Config) (*replica, error) {
+	replicaConf := dbConf.Replica
+	conf := dbConf
+	conf.Host = replicaConf.Host
+	if replicaConf.Port != 0 {
+		conf.Port = replicaConf.Port
+	}
+	if replicaConf.Name != "" {
+		conf.Name = replicaConf.Name
+	}
+	if replicaConf.User != "" {
+		conf.User = replicaConf.User
+		conf.Password = replicaConf.Password
+	}
+	replicaDSN, err := dsn(conf)
+	if err != nil {
+		return nil, err
+	}
+	gormConfig.DisableAutomaticPing = true
+	gormDB, err := gorm.Open(postgres.Open(replicaDSN), &gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replica: %w", err)
+	}
+	if err := configurePool(gormDB, dbConf.Pool); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to configure replica pool: %w", err), closeGorm(gormDB))
+	}
+	r := &replica{
+		gorm:     gormDB,
+		maxLag:   replicaConf.MaxLag,
+		interval: replicaConf.CheckInterval,
+	}
+	if r.maxLag <= 0 {
+		r.maxLag = defaultReplicaMaxLag
+	}
+	if r.interval <= 0 {
+		r.interval = defaultReplicaCheckInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.check(ctx)
+	r.wg.Add(1)
+	go r.monitor(ctx)
+	return r, nil
+}
+
+// Healthy reports whether reads may go to the replica.
+func (r *replica) Healthy() bool {
+	return r.healthy.Load()
+}
+
+func (r *replica) monitor(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check(ctx)
+		}
+	}
+}
+
+// check measures the replica's lag and updates its health. Transitions are
+// logged so a fallback to the primary is visible.
+func (r *replica) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, r.interval)
+	defer cancel()
+	var lagSeconds float64
+	err := r.gorm.WithContext(ctx).Raw(replicaLagQuery).Scan(&lagSeconds).Error
+	lag := time.Duration(lagSeconds * float64(time.Second))
+	healthy := err == nil && lag <= r.maxLag
+	metrics.RecordReplicaHealth(healthy, lag)
+	if was := r.healthy.Swap(healthy); was != healthy {
+		if healthy {
+			log.Info("replica healthy, reading from replica", "lag", lag)
+		} else {
+			log.Warn("replica unhealthy, reading from primary", "lag", lag, "max_lag", r.maxLag, "err", err)
+		}
+	}
+}
+
+func (r *replica) close() error {
+	r.cancel()
+	r.wg.Wait()
+	return closeGorm(r.gorm)
+}
+
+func closeGorm(gormDB *gorm.DB) error {
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// replicaKeysView sends key reads to the replica while it is healthy and to
+// the primary otherwise.
+type replicaKeysView struct {
+	primary KeysView
+	replica KeysView
+	health  *replica
+}
+
+func (v *replicaKeysView) view() KeysView {
+	if v.health.Healthy() {
+		return v.replica
+	}
+	return v.primary
+}
+
+func (v *replicaKeysView) QueryKeysByBusId(businessId string, page, pageSize uint64) ([]Keys, int64, error) {
+	return v.view().QueryKeysByBusId(businessId, page, pageSize)
+}
+
+func (v *replicaKeysView) QueryKeyByPublicKey(publicKey string) (*Keys, error) {
+	return v.view().QueryKeyByPublicKey(publicKey)
+}
+
+func (v *replicaKeysView) QueryKeyByAddress(address string) (*Keys, error) {
+	return v.view().QueryKeyByAddress(address)
+}
+
+// RegisterMetrics exports the connection pool stats of the primary, and of
+// the read replica when one is configured: open, in-use and idle connections,
+// the configured maximum, and how often and how long callers waited for a
+// connection.
+func (db *DB) RegisterMetrics() error {
+	pools := map[string]*gorm.DB{"primary": db.gorm}
+	if db.replica != nil {
+		pools["replica"] = db.replica.gorm
+	}
+	for name, pool := range pools {
+		sqlDB, err := pool.DB()
+		if err != nil {
+			return err
+		}
+		if err := metrics.RegisterDB(name, sqlDB); err != nil {
+			return fmt.Errorf("failed to register %s DB metrics: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import "testing"
+
+type namedKeysView string
+
+func (v namedKeysView) QueryKeysByBusId(string, uint64, uint64) ([]Keys, int64, error) {
+	return []Keys{{BusinessId: string(v)}}, 1, nil
+}
+
+func (v namedKeysView) QueryKeyByPublicKey(string) (*Keys, error) {
+	return &Keys{BusinessId: string(v)}, nil
+}
+
+func (v namedKeysView) QueryKeyByAddress(string) (*Keys, error) {
+	return &Keys{BusinessId: string(v)}, nil
+}
+
+func TestReplicaKeysView(t *testing.T) {
+	health := &replica{}
+	view := &replicaKeysView{primary: namedKeysView("primary"), replica: namedKeysView("replica"), health: health}
+
+	if k, _ := view.QueryKeyByAddress("addr"); k.BusinessId != "primary" {
+		t.Fatalf("unhealthy replica served the read")
+	}
+	health.healthy.Store(true)
+	if k, _ := view.QueryKeyByPublicKey("pub"); k.BusinessId != "replica" {
+		t.Fatalf("healthy replica not used")
+	}
+	if keyList, _, _ := view.QueryKeysByBusId("merchant-1", 1, 20); keyList[0].BusinessId != "replica" {
+		t.Fatalf("healthy replica not used for listing")
+	}
+}
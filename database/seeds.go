@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Seeds is a row of the seeds table: the BIP-39 mnemonic of a business,
+// sealed the same way as Keys.PrivateKey.
+type Seeds struct {
+	BusinessId string `gorm:"primaryKey" json:"business_id"`
+	Mnemonic   string `json:"mnemonic"`
+	DataKey    string `json:"data_key"`
+	KeyVersion uint32 `json:"key_version"`
+	Timestamp  uint64
+}
+
+type SeedsView interface {
+	QuerySeedByBusId(string) (*Seeds, error)
+}
+
+type SeedsDB interface {
+	SeedsView
+
+	StoreSeed(*Seeds) error
+	LockSeedByBusId(string) (*Seeds, error)
+	QuerySeedsNotSealedWith(uint32, int) ([]Seeds, error)
+	UpdateSealedSeed(*Seeds, uint32) (bool, error)
+}
+
+type seedsDB struct {
+	gorm *gorm.DB
+}
+
+func NewSeedsDB(db *gorm.DB) SeedsDB {
+	return &seedsDB{gorm: db}
+}
+
+// StoreSeed inserts the seed of a business. A seed that already exists for
+// the business is kept, so concurrent first requests end up sharing one seed.
+func (db *seedsDB) StoreSeed(seed *Seeds) error {
+	return db.gorm.Clauses(clause.OnConflict{DoNothing: true}).Create(seed).Error
+}
+
+// QuerySeedByBusId returns the seed of a business, or nil if it has none yet.
+func (db *seedsDB) QuerySeedByBusId(busId string) (*Seeds, error) {
+	return db.querySeed(db.gorm, busId)
+}
+
+// LockSeedByBusId returns the seed of a business and locks its row until the
+// surrounding transaction ends, serializing derivation index allocation.
+// It returns nil if the business has no seed yet.
+func (db *seedsDB) LockSeedByBusId(busId string) (*Seeds, error) {
+	return db.querySeed(db.gorm.Clauses(clause.Locking{Strength: "UPDATE"}), busId)
+}
+
+func (db *seedsDB) querySeed(tx *gorm.DB, busId string) (*Seeds, error) {
+	var seed Seeds
+	result := tx.Where("business_id = ?", busId).Take(&seed)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &seed, nil
+}
+
+// QuerySeedsNotSealedWith returns up to limit seeds that are not sealed under
+// the given master key version, ordered by business id.
+func (db *seedsDB) QuerySeedsNotSealedWith(keyVersion uint32, limit int) ([]Seeds, error) {
+	var seedList []Seeds
+	err := db.gorm.Where("key_version <> ?", keyVersion).
+		Order("business_id ASC").
+		Limit(limit).
+		Find(&seedList).Error
+	return seedList, err
+}
+
+// UpdateSealedSeed stores the resealed mnemonic, data key and key version of
+// seed, provided the row is still sealed under fromVersion. It reports
+// whether the row was updated.
+func (db *seedsDB) UpdateSealedSeed(seed *Seeds, fromVersion uint32) (bool, error) {
+	result := db.gorm.Model(&Seeds{}).
+		Where("business_id = ? AND key_version = ?", seed.BusinessId, fromVersion).
+		Updates(map[string]any{
+			"mnemonic":    seed.Mnemonic,
+			"data_key":    seed.DataKey,
+			"key_version": seed.KeyVersion,
+		})
+	return result.RowsAffected == 1, result.Error
+}
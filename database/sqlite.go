@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+)
+
+// SQLiteMemory is the sqlite path of an in-memory database.
+const SQLiteMemory = ":memory:"
+
+// sqliteBusyTimeout makes a writer wait for the lock of a concurrent writer
+// instead of failing with SQLITE_BUSY.
+const sqliteBusyTimeout = "?_pragma=busy_timeout(5000)"
+
+// openSQLite opens the sqlite database at dbConf.Path, creating the file if
+// needed. It needs no server, so the service can run on a laptop.
+//
+// The pool holds a single connection that is never recycled: sqlite
+// serializes writers anyway, and every connection to ":memory:" would open
+// a new, empty database.
+//
+// Parameters:
+//   - dbConf: The database configuration. Only Path is used.
+//   - gormConfig: The gorm configuration.
+//
+// Returns:
+//   - *DB: The database.
+//   - error: An error if a read replica is configured or the file cannot be opened.
+func openSQLite(dbConf config.DBConfig, gormConfig gorm.Config) (*DB, error) {
+	if dbConf.Path == "" {
+		return nil, errors.New("the sqlite driver needs a database path")
+	}
+	if dbConf.Replica.Host != "" {
+		return nil, errors.New("the sqlite driver does not support a read replica")
+	}
+	gormDB, err := gorm.Open(sqlite.Open(dbConf.Path+sqliteBusyTimeout), &gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+	log.Info("opened sqlite database", "path", dbConf.Path)
+	db := newDB(gormDB)
+	db.inMemory = dbConf.Path == SQLiteMemory
+	return db, nil
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+)
+
+// newTestDB opens an empty in-memory sqlite database.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := NewDB(context.Background(), config.DBConfig{Driver: DriverSQLite, Path: SQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// newMigratedTestDB opens an in-memory sqlite database with every embedded
+// migration applied.
+func newMigratedTestDB(t *testing.T) *DB {
+	t.Helper()
+	db := newTestDB(t)
+	if err := db.ExecuteSQLMigration(""); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestNewDBDriver(t *testing.T) {
+	if _, err := NewDB(context.Background(), config.DBConfig{Driver: "mysql"}); err == nil {
+		t.Fatal("unknown driver accepted")
+	}
+	if _, err := NewDB(context.Background(), config.DBConfig{}); err == nil {
+		t.Fatal("postgres driver accepted without a host")
+	}
+	replicaConf := config.DBConfig{Driver: DriverSQLite, Path: SQLiteMemory, Replica: config.ReplicaConfig{Host: "replica"}}
+	if _, err := NewDB(context.Background(), replicaConf); err == nil {
+		t.Fatal("sqlite driver accepted a read replica")
+	}
+	if driver := newTestDB(t).Driver(); driver != DriverSQLite {
+		t.Fatalf("got driver %s, want %s", driver, DriverSQLite)
+	}
+}
+
+func TestSQLiteKeys(t *testing.T) {
+	db := newMigratedTestDB(t)
+	index := uint32(3)
+	keyList := []Keys{
+		{GUID: uuid.New(), BusinessId: "merchant-1", PublicKey: "pub-1", Address: "addr-1", Chain: "Ethereum", Network: "MainNet", DerivationPath: "m/44'/60'/0'/0/3", DerivationIndex: &index, Timestamp: 1},
+		{GUID: uuid.New(), PublicKey: "pub-2", Address: "addr-2", Chain: "Ethereum", Network: "MainNet", Pooled: true, Timestamp: 2},
+	}
+	if err := db.Keys.StoreKeys(keyList, CreateBatchSize); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := db.KeysView.QueryKeyByAddress("addr-1"); err != nil || key.GUID != keyList[0].GUID {
+		t.Fatalf("got %+v, %v", key, err)
+	}
+	if maxIndex, err := db.Keys.QueryMaxDerivationIndex("merchant-1", "m/44'/60'/0'/0"); err != nil || maxIndex == nil || *maxIndex != index {
+		t.Fatalf("got max derivation index %v, %v", maxIndex, err)
+	}
+
+	err := db.Transaction(func(tx *DB) error {
+		key, err := tx.Keys.AssignPooledKey("merchant-1", "Ethereum", "MainNet", "", 3)
+		if err != nil {
+			return err
+		}
+		if key == nil || key.GUID != keyList[1].GUID || key.BusinessId != "merchant-1" || key.Pooled {
+			t.Fatalf("unexpected pooled key %+v", key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := db.Keys.AssignPooledKey("merchant-1", "Ethereum", "MainNet", "", 4); err != nil || key != nil {
+		t.Fatalf("empty pool returned %+v, %v", key, err)
+	}
+	page, total, err := db.KeysView.QueryKeysByBusId("merchant-1", 1, DefaultPageSize)
+	if err != nil || total != 2 || len(page) != 2 {
+		t.Fatalf("got %d of %d keys: %v", len(page), total, err)
+	}
+	if _, _, err := db.KeysView.QueryKeysByBusId("merchant-1", math.MaxUint64, DefaultPageSize); !errors.Is(err, ErrInvalidPage) {
+		t.Errorf("page above MaxPage: got %v, want ErrInvalidPage", err)
+	}
+	if _, _, err := db.KeysView.QueryKeysByBusId("merchant-1", 1, MaxPageSize+1); !errors.Is(err, ErrInvalidPageSize) {
+		t.Errorf("page size above MaxPageSize: got %v, want ErrInvalidPageSize", err)
+	}
+}
+
+func TestSQLiteSeedsAndTokens(t *testing.T) {
+	db := newMigratedTestDB(t)
+	if err := db.Seeds.StoreSeed(&Seeds{BusinessId: "merchant-1", Mnemonic: "first", Timestamp: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Seeds.StoreSeed(&Seeds{BusinessId: "merchant-1", Mnemonic: "second", Timestamp: 2}); err != nil {
+		t.Fatal(err)
+	}
+	err := db.Transaction(func(tx *DB) error {
+		seed, err := tx.Seeds.LockSeedByBusId("merchant-1")
+		if err != nil {
+			return err
+		}
+		if seed == nil || seed.Mnemonic != "first" {
+			t.Fatalf("existing seed replaced: %+v", seed)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := &Tokens{GUID: uuid.New(), TokenHash: "hash", BusinessId: "merchant-1", Scopes: "sign", Timestamp: 1}
+	if err := db.Tokens.StoreToken(token); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := db.Tokens.RevokeToken(token.GUID); err != nil || !revoked {
+		t.Fatalf("token not revoked: %v", err)
+	}
+	stored, err := db.Tokens.QueryTokenByHash("hash")
+	if err != nil || stored == nil || !stored.Revoked || stored.Scopes != "sign" {
+		t.Fatalf("got %+v, %v", stored, err)
+	}
+}
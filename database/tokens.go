@@ -0,0 +1,78 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tokens is a row of the tokens table: a consumer token or REST API key bound
+// to a business id and a comma separated list of scopes. Only the SHA-256
+// hash of the token is stored.
+type Tokens struct {
+	GUID       uuid.UUID `gorm:"primaryKey" json:"guid"`
+	TokenHash  string    `json:"token_hash"`
+	BusinessId string    `json:"business_id"`
+	Scopes     string    `json:"scopes"`
+	Revoked    bool      `json:"revoked"`
+	Timestamp  uint64
+}
+
+type TokensView interface {
+	QueryTokenByHash(string) (*Tokens, error)
+	QueryTokensByBusId(string) ([]Tokens, error)
+}
+
+type TokensDB interface {
+	TokensView
+
+	StoreToken(*Tokens) error
+	RevokeToken(uuid.UUID) (bool, error)
+}
+
+type tokensDB struct {
+	gorm *gorm.DB
+}
+
+func NewTokensDB(db *gorm.DB) TokensDB {
+	return &tokensDB{gorm: db}
+}
+
+func (db *tokensDB) StoreToken(token *Tokens) error {
+	return db.gorm.Create(token).Error
+}
+
+// RevokeToken marks a token as revoked. It reports whether a token with the
+// given guid exists.
+func (db *tokensDB) RevokeToken(guid uuid.UUID) (bool, error) {
+	result := db.gorm.Model(&Tokens{}).Where("guid = ?", guid).Update("revoked", true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// QueryTokenByHash returns the token with the given hash, or nil if no such
+// token has been issued.
+func (db *tokensDB) QueryTokenByHash(tokenHash string) (*Tokens, error) {
+	var token Tokens
+	result := db.gorm.Where("token_hash = ?", tokenHash).Take(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &token, nil
+}
+
+// QueryTokensByBusId returns the tokens issued to a business id, oldest first.
+func (db *tokensDB) QueryTokensByBusId(busId string) ([]Tokens, error) {
+	var tokenList []Tokens
+	err := db.gorm.Where("business_id = ?", busId).
+		Order("timestamp ASC").
+		Order("guid ASC").
+		Find(&tokenList).Error
+	return tokenList, err
+}
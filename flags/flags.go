@@ -1,6 +1,10 @@
 package flags
 
-import "github.com/urfave/cli/v2"
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
 
 const evnVarPrefix = "SIGNATURE"
 
@@ -9,10 +13,15 @@ func prefixEnvVars(name string) []string {
 }
 
 var (
+	ConfigFileFlag = &cli.StringFlag{
+		Name:    "config",
+		Value:   "./config.yml",
+		Usage:   "path of the yaml config file listing the supported chains",
+		EnvVars: prefixEnvVars("CONFIG"),
+	}
 	MigrationsFlag = &cli.StringFlag{
 		Name:    "migrations-dir",
-		Value:   "./migrations",
-		Usage:   "path for database migrations",
+		Usage:   "path for database migrations, overriding the migrations embedded in the binary; it holds one folder per driver, postgres and sqlite",
 		EnvVars: prefixEnvVars("MIGRATIONS_DIR"),
 	}
 	// RpcHostFlag RPC Service
@@ -38,11 +47,17 @@ var (
 	}
 	MetricsPortFlag = &cli.IntFlag{
 		Name:     "metrics-port",
-		Usage:    "The port of the metrics",
+		Usage:    "The port of the metrics of the rest api",
 		EnvVars:  prefixEnvVars("METRICS_PORT"),
 		Value:    7214,
 		Required: true,
 	}
+	RpcMetricsPortFlag = &cli.IntFlag{
+		Name:    "rpc-metrics-port",
+		Usage:   "The port of the metrics of the rpc service, distinct from --metrics-port so both can run on one host",
+		EnvVars: prefixEnvVars("RPC_METRICS_PORT"),
+		Value:   7215,
+	}
 	// HttpHostFlag HTTP Service
 	HttpHostFlag = &cli.StringFlag{
 		Name:     "http-host",
@@ -57,39 +72,197 @@ var (
 		Required: true,
 	}
 
-	// DbHostFlag Database
+	// DbDriverFlag Database
+	DbDriverFlag = &cli.StringFlag{
+		Name:    "db-driver",
+		Usage:   "The storage backend: postgres, or sqlite for a local file or in-memory database",
+		EnvVars: prefixEnvVars("DB_DRIVER"),
+		Value:   "postgres",
+	}
+	DbPathFlag = &cli.StringFlag{
+		Name:    "db-path",
+		Usage:   "The sqlite database file, or :memory: for an in-memory database",
+		EnvVars: prefixEnvVars("DB_PATH"),
+		Value:   "go-signature.db",
+	}
 	DbHostFlag = &cli.StringFlag{
-		Name:     "master-db-host",
-		Usage:    "The host of the master database",
-		EnvVars:  prefixEnvVars("DB_HOST"),
-		Required: true,
+		Name:    "master-db-host",
+		Usage:   "The host of the master database, required by the postgres driver",
+		EnvVars: prefixEnvVars("DB_HOST"),
 	}
 	DbPortFlag = &cli.IntFlag{
-		Name:     "master-db-port",
-		Usage:    "The port of the master database",
-		EnvVars:  prefixEnvVars("DB_PORT"),
-		Required: true,
+		Name:    "master-db-port",
+		Usage:   "The port of the master database",
+		EnvVars: prefixEnvVars("DB_PORT"),
 	}
 	DbUserFlag = &cli.StringFlag{
-		Name:     "master-db-user",
-		Usage:    "The user of the master database",
-		EnvVars:  prefixEnvVars("DB_USER"),
-		Required: true,
+		Name:    "master-db-user",
+		Usage:   "The user of the master database",
+		EnvVars: prefixEnvVars("DB_USER"),
 	}
 	DbPasswordFlag = &cli.StringFlag{
-		Name:     "master-db-password",
-		Usage:    "The host of the master database",
-		EnvVars:  prefixEnvVars("DB_PASSWORD"),
-		Required: true,
+		Name:    "master-db-password",
+		Usage:   "The host of the master database",
+		EnvVars: prefixEnvVars("DB_PASSWORD"),
 	}
 	DbNameFlag = &cli.StringFlag{
-		Name:     "master-db-name",
-		Usage:    "The db name of the master database",
-		EnvVars:  prefixEnvVars("DB_NAME"),
-		Required: true,
+		Name:    "master-db-name",
+		Usage:   "The db name of the master database, required by the postgres driver",
+		EnvVars: prefixEnvVars("DB_NAME"),
+	}
+	// DbSSLModeFlag TLS and connection pool, shared by the master and the read replica
+	DbSSLModeFlag = &cli.StringFlag{
+		Name:    "db-sslmode",
+		Usage:   "The sslmode of the database connections: disable, allow, prefer, require, verify-ca or verify-full",
+		EnvVars: prefixEnvVars("DB_SSLMODE"),
+		Value:   "disable",
+	}
+	DbSSLRootCertFlag = &cli.StringFlag{
+		Name:    "db-sslrootcert",
+		Usage:   "The path of the CA certificate the database server certificate is verified against",
+		EnvVars: prefixEnvVars("DB_SSLROOTCERT"),
+	}
+	DbSSLCertFlag = &cli.StringFlag{
+		Name:    "db-sslcert",
+		Usage:   "The path of the client certificate presented to the database",
+		EnvVars: prefixEnvVars("DB_SSLCERT"),
+	}
+	DbSSLKeyFlag = &cli.StringFlag{
+		Name:    "db-sslkey",
+		Usage:   "The path of the private key of the client certificate",
+		EnvVars: prefixEnvVars("DB_SSLKEY"),
+	}
+	DbMaxOpenConnsFlag = &cli.IntFlag{
+		Name:    "db-max-open-conns",
+		Usage:   "The maximum number of open connections per database pool, 0 for unlimited",
+		EnvVars: prefixEnvVars("DB_MAX_OPEN_CONNS"),
+		Value:   25,
+	}
+	DbMaxIdleConnsFlag = &cli.IntFlag{
+		Name:    "db-max-idle-conns",
+		Usage:   "The maximum number of idle connections per database pool",
+		EnvVars: prefixEnvVars("DB_MAX_IDLE_CONNS"),
+		Value:   10,
+	}
+	DbConnMaxLifetimeFlag = &cli.DurationFlag{
+		Name:    "db-conn-max-lifetime",
+		Usage:   "The maximum time a database connection is reused, 0 for no limit",
+		EnvVars: prefixEnvVars("DB_CONN_MAX_LIFETIME"),
+		Value:   30 * time.Minute,
+	}
+	DbStatementTimeoutFlag = &cli.DurationFlag{
+		Name:    "db-statement-timeout",
+		Usage:   "The time after which a database statement is aborted, 0 for the server default",
+		EnvVars: prefixEnvVars("DB_STATEMENT_TIMEOUT"),
+	}
+	// ReplicaDbHostFlag Read replica, disabled when no host is set
+	ReplicaDbHostFlag = &cli.StringFlag{
+		Name:    "replica-db-host",
+		Usage:   "The host of the read replica database",
+		EnvVars: prefixEnvVars("REPLICA_DB_HOST"),
+	}
+	ReplicaDbPortFlag = &cli.IntFlag{
+		Name:    "replica-db-port",
+		Usage:   "The port of the read replica database, defaults to the master's",
+		EnvVars: prefixEnvVars("REPLICA_DB_PORT"),
+	}
+	ReplicaDbUserFlag = &cli.StringFlag{
+		Name:    "replica-db-user",
+		Usage:   "The user of the read replica database, defaults to the master's",
+		EnvVars: prefixEnvVars("REPLICA_DB_USER"),
+	}
+	ReplicaDbPasswordFlag = &cli.StringFlag{
+		Name:    "replica-db-password",
+		Usage:   "The password of the read replica database",
+		EnvVars: prefixEnvVars("REPLICA_DB_PASSWORD"),
+	}
+	ReplicaDbNameFlag = &cli.StringFlag{
+		Name:    "replica-db-name",
+		Usage:   "The db name of the read replica database, defaults to the master's",
+		EnvVars: prefixEnvVars("REPLICA_DB_NAME"),
+	}
+	ReplicaMaxLagFlag = &cli.DurationFlag{
+		Name:    "replica-max-lag",
+		Usage:   "The replication lag beyond which reads fall back to the master database",
+		EnvVars: prefixEnvVars("REPLICA_MAX_LAG"),
+		Value:   10 * time.Second,
+	}
+	ReplicaCheckIntervalFlag = &cli.DurationFlag{
+		Name:    "replica-check-interval",
+		Usage:   "The interval between read replica health checks",
+		EnvVars: prefixEnvVars("REPLICA_CHECK_INTERVAL"),
+		Value:   5 * time.Second,
+	}
+
+	// MasterKeyFlag Key encryption
+	MasterKeyFlag = &cli.StringFlag{
+		Name:    "master-key",
+		Usage:   "The hex encoded master key used to encrypt private keys at rest",
+		EnvVars: prefixEnvVars("MASTER_KEY"),
+	}
+	MasterKeyFileFlag = &cli.StringFlag{
+		Name:    "master-key-file",
+		Usage:   "The path of a file holding the hex encoded master key",
+		EnvVars: prefixEnvVars("MASTER_KEY_FILE"),
+	}
+	MasterKeyRetiredFileFlag = &cli.StringFlag{
+		Name:    "master-key-retired-file",
+		Usage:   "The path of a file listing retired master keys as <version>:<hex key> lines, used to read keys sealed before a rotation",
+		EnvVars: prefixEnvVars("MASTER_KEY_RETIRED_FILE"),
+	}
+	MasterKeyVersionFlag = &cli.UintFlag{
+		Name:    "master-key-version",
+		Usage:   "The version of the master key, recorded with every encrypted private key",
+		EnvVars: prefixEnvVars("MASTER_KEY_VERSION"),
+		Value:   1,
+	}
+
+	// AddressPoolSizeFlag Address pool
+	AddressPoolSizeFlag = &cli.IntFlag{
+		Name:    "address-pool-size",
+		Usage:   "The number of pre-generated unassigned keys kept per chain and network, 0 disables the pool; set it for one of the api and rpc processes only",
+		EnvVars: prefixEnvVars("ADDRESS_POOL_SIZE"),
+		Value:   0,
+	}
+	AddressPoolLowWaterFlag = &cli.IntFlag{
+		Name:    "address-pool-low-water",
+		Usage:   "The number of unassigned keys under which a pool is refilled",
+		EnvVars: prefixEnvVars("ADDRESS_POOL_LOW_WATER"),
+		Value:   25,
+	}
+	AddressPoolRefillIntervalFlag = &cli.DurationFlag{
+		Name:    "address-pool-refill-interval",
+		Usage:   "How often the address pools are checked for refill",
+		EnvVars: prefixEnvVars("ADDRESS_POOL_REFILL_INTERVAL"),
+		Value:   30 * time.Second,
 	}
 )
 
+// BusinessIdFlag, ScopesFlag and TokenIdFlag configure the token and seed
+// commands.
+var (
+	BusinessIdFlag = &cli.StringFlag{
+		Name:  "business-id",
+		Usage: "The business id a consumer token is issued to or listed for, or whose seed is exported or imported",
+	}
+	ScopesFlag = &cli.StringSliceFlag{
+		Name:  "scopes",
+		Usage: "The scopes granted to an issued consumer token",
+		Value: cli.NewStringSlice("address:create", "keys:read", "sign"),
+	}
+	TokenIdFlag = &cli.StringFlag{
+		Name:  "token-id",
+		Usage: "The guid of the consumer token to revoke",
+	}
+)
+
+// StepsFlag configures the migrate down command.
+var StepsFlag = &cli.IntFlag{
+	Name:  "steps",
+	Usage: "The number of applied migrations to revert",
+	Value: 1,
+}
+
 var requireFlags = []cli.Flag{
 	MigrationsFlag,
 	RpcHostFlag,
@@ -98,15 +271,42 @@ var requireFlags = []cli.Flag{
 	MetricsHostFlag,
 	HttpHostFlag,
 	HttpPortFlag,
+}
+
+var optionalFlags = []cli.Flag{
+	RpcMetricsPortFlag,
+	ConfigFileFlag,
+	DbDriverFlag,
+	DbPathFlag,
 	DbHostFlag,
 	DbPortFlag,
 	DbUserFlag,
 	DbPasswordFlag,
 	DbNameFlag,
+	DbSSLModeFlag,
+	DbSSLRootCertFlag,
+	DbSSLCertFlag,
+	DbSSLKeyFlag,
+	DbMaxOpenConnsFlag,
+	DbMaxIdleConnsFlag,
+	DbConnMaxLifetimeFlag,
+	DbStatementTimeoutFlag,
+	ReplicaDbHostFlag,
+	ReplicaDbPortFlag,
+	ReplicaDbUserFlag,
+	ReplicaDbPasswordFlag,
+	ReplicaDbNameFlag,
+	ReplicaMaxLagFlag,
+	ReplicaCheckIntervalFlag,
+	MasterKeyFlag,
+	MasterKeyFileFlag,
+	MasterKeyRetiredFileFlag,
+	MasterKeyVersionFlag,
+	AddressPoolSizeFlag,
+	AddressPoolLowWaterFlag,
+	AddressPoolRefillIntervalFlag,
 }
 
-var optionalFlags = []cli.Flag{}
-
 // init initializes the Flags variable by combining required and optional flags.
 //
 // It appends the optionalFlags slice to the requireFlags slice and assigns
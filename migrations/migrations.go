@@ -0,0 +1,14 @@
+// Package migrations embeds the SQL migrations of the service so the binary
+// can migrate a database without the migrations folder next to it.
+//
+// Every supported database has its own folder of migrations, named after the
+// gorm dialect: postgres and sqlite. Both folders use the same versions, so a
+// schema change is added to each of them.
+package migrations
+
+import "embed"
+
+// FS holds one folder of <version>_<name>.sql migration files per dialect.
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var FS embed.FS
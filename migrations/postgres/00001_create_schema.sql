@@ -4,4 +4,7 @@ CREATE TABLE IF NOT EXISTS keys (
     private_key VARCHAR NOT NULL,
     public_key VARCHAR NOT NULL,
     timestamp INTEGER NOT NULL CHECK (timestamp> 0)
-)
\ No newline at end of file
+);
+
+-- +down
+DROP TABLE IF EXISTS keys;
@@ -0,0 +1,6 @@
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS data_key VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS key_version INTEGER NOT NULL DEFAULT 0;
+
+-- +down
+ALTER TABLE keys DROP COLUMN IF EXISTS key_version;
+ALTER TABLE keys DROP COLUMN IF EXISTS data_key;
@@ -0,0 +1,10 @@
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS address VARCHAR NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS keys_address ON keys (address);
+CREATE INDEX IF NOT EXISTS keys_public_key ON keys (public_key);
+CREATE INDEX IF NOT EXISTS keys_business_id_timestamp ON keys (business_id, timestamp, guid);
+
+-- +down
+DROP INDEX IF EXISTS keys_business_id_timestamp;
+DROP INDEX IF EXISTS keys_public_key;
+DROP INDEX IF EXISTS keys_address;
+ALTER TABLE keys DROP COLUMN IF EXISTS address;
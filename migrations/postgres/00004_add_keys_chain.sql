@@ -0,0 +1,8 @@
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS chain VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS network VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS address_type VARCHAR NOT NULL DEFAULT '';
+
+-- +down
+ALTER TABLE keys DROP COLUMN IF EXISTS address_type;
+ALTER TABLE keys DROP COLUMN IF EXISTS network;
+ALTER TABLE keys DROP COLUMN IF EXISTS chain;
@@ -0,0 +1,6 @@
+ALTER TABLE keys ADD COLUMN IF NOT EXISTS pooled BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS keys_pool ON keys (chain, network, address_type) WHERE pooled;
+
+-- +down
+DROP INDEX IF EXISTS keys_pool;
+ALTER TABLE keys DROP COLUMN IF EXISTS pooled;
@@ -0,0 +1,4 @@
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS scopes VARCHAR NOT NULL DEFAULT 'address:create,keys:read,sign';
+
+-- +down
+ALTER TABLE tokens DROP COLUMN IF EXISTS scopes;
@@ -0,0 +1,6 @@
+ALTER TABLE keys ADD COLUMN data_key VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE keys ADD COLUMN key_version INTEGER NOT NULL DEFAULT 0;
+
+-- +down
+ALTER TABLE keys DROP COLUMN key_version;
+ALTER TABLE keys DROP COLUMN data_key;
@@ -0,0 +1,8 @@
+ALTER TABLE keys ADD COLUMN chain VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE keys ADD COLUMN network VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE keys ADD COLUMN address_type VARCHAR NOT NULL DEFAULT '';
+
+-- +down
+ALTER TABLE keys DROP COLUMN address_type;
+ALTER TABLE keys DROP COLUMN network;
+ALTER TABLE keys DROP COLUMN chain;
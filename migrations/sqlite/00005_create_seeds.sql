@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS seeds (
+    business_id VARCHAR PRIMARY KEY,
+    mnemonic VARCHAR NOT NULL,
+    data_key VARCHAR NOT NULL,
+    key_version INTEGER NOT NULL,
+    timestamp INTEGER NOT NULL CHECK (timestamp> 0)
+);
+
+ALTER TABLE keys ADD COLUMN derivation_path VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE keys ADD COLUMN derivation_index BIGINT;
+CREATE INDEX IF NOT EXISTS keys_derivation ON keys (business_id, chain, network, address_type, derivation_index);
+
+-- +down
+DROP INDEX IF EXISTS keys_derivation;
+ALTER TABLE keys DROP COLUMN derivation_index;
+ALTER TABLE keys DROP COLUMN derivation_path;
+DROP TABLE IF EXISTS seeds;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tokens (
+    guid VARCHAR PRIMARY KEY,
+    token_hash VARCHAR NOT NULL UNIQUE,
+    business_id VARCHAR NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    timestamp INTEGER NOT NULL CHECK (timestamp> 0)
+);
+CREATE INDEX IF NOT EXISTS tokens_business_id ON tokens (business_id);
+
+-- +down
+DROP TABLE IF EXISTS tokens;
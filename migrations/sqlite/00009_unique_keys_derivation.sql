@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS keys_derivation;
+CREATE UNIQUE INDEX IF NOT EXISTS keys_derivation ON keys (business_id, chain, network, address_type, derivation_index);
+
+-- +down
+DROP INDEX IF EXISTS keys_derivation;
+CREATE INDEX IF NOT EXISTS keys_derivation ON keys (business_id, chain, network, address_type, derivation_index);
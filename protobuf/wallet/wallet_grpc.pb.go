@@ -19,8 +19,15 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	WalletService_GetSupportCoins_FullMethodName  = "/the_web_three.wallet.WalletService/getSupportCoins"
-	WalletService_GetWalletAddress_FullMethodName = "/the_web_three.wallet.WalletService/getWalletAddress"
+	WalletService_GetSupportCoins_FullMethodName      = "/the_web_three.wallet.WalletService/getSupportCoins"
+	WalletService_GetWalletAddress_FullMethodName     = "/the_web_three.wallet.WalletService/getWalletAddress"
+	WalletService_ListKeys_FullMethodName             = "/the_web_three.wallet.WalletService/listKeys"
+	WalletService_SignTransaction_FullMethodName      = "/the_web_three.wallet.WalletService/signTransaction"
+	WalletService_SignMessage_FullMethodName          = "/the_web_three.wallet.WalletService/signMessage"
+	WalletService_SignTypedData_FullMethodName        = "/the_web_three.wallet.WalletService/signTypedData"
+	WalletService_SignPsbt_FullMethodName             = "/the_web_three.wallet.WalletService/signPsbt"
+	WalletService_BatchCreateAddresses_FullMethodName = "/the_web_three.wallet.WalletService/batchCreateAddresses"
+	WalletService_ListSupportedChains_FullMethodName  = "/the_web_three.wallet.WalletService/listSupportedChains"
 )
 
 // WalletServiceClient is the client API for WalletService service.
@@ -29,6 +36,13 @@ const (
 type WalletServiceClient interface {
 	GetSupportCoins(ctx context.Context, in *SupportCoinsRequest, opts ...grpc.CallOption) (*SupportCoinsResponse, error)
 	GetWalletAddress(ctx context.Context, in *WalletAddressRequest, opts ...grpc.CallOption) (*WalletAddressResponse, error)
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error)
+	SignTransaction(ctx context.Context, in *SignTransactionRequest, opts ...grpc.CallOption) (*SignTransactionResponse, error)
+	SignMessage(ctx context.Context, in *SignMessageRequest, opts ...grpc.CallOption) (*SignatureResponse, error)
+	SignTypedData(ctx context.Context, in *SignTypedDataRequest, opts ...grpc.CallOption) (*SignatureResponse, error)
+	SignPsbt(ctx context.Context, in *SignPsbtRequest, opts ...grpc.CallOption) (*SignPsbtResponse, error)
+	BatchCreateAddresses(ctx context.Context, in *BatchCreateAddressesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BatchCreateAddressesResponse], error)
+	ListSupportedChains(ctx context.Context, in *ListSupportedChainsRequest, opts ...grpc.CallOption) (*ListSupportedChainsResponse, error)
 }
 
 type walletServiceClient struct {
@@ -59,12 +73,98 @@ func (c *walletServiceClient) GetWalletAddress(ctx context.Context, in *WalletAd
 	return out, nil
 }
 
+func (c *walletServiceClient) ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListKeysResponse)
+	err := c.cc.Invoke(ctx, WalletService_ListKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) SignTransaction(ctx context.Context, in *SignTransactionRequest, opts ...grpc.CallOption) (*SignTransactionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignTransactionResponse)
+	err := c.cc.Invoke(ctx, WalletService_SignTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) SignMessage(ctx context.Context, in *SignMessageRequest, opts ...grpc.CallOption) (*SignatureResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignatureResponse)
+	err := c.cc.Invoke(ctx, WalletService_SignMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) SignTypedData(ctx context.Context, in *SignTypedDataRequest, opts ...grpc.CallOption) (*SignatureResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignatureResponse)
+	err := c.cc.Invoke(ctx, WalletService_SignTypedData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) SignPsbt(ctx context.Context, in *SignPsbtRequest, opts ...grpc.CallOption) (*SignPsbtResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignPsbtResponse)
+	err := c.cc.Invoke(ctx, WalletService_SignPsbt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) BatchCreateAddresses(ctx context.Context, in *BatchCreateAddressesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BatchCreateAddressesResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WalletService_ServiceDesc.Streams[0], WalletService_BatchCreateAddresses_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchCreateAddressesRequest, BatchCreateAddressesResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WalletService_BatchCreateAddressesClient = grpc.ServerStreamingClient[BatchCreateAddressesResponse]
+
+func (c *walletServiceClient) ListSupportedChains(ctx context.Context, in *ListSupportedChainsRequest, opts ...grpc.CallOption) (*ListSupportedChainsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSupportedChainsResponse)
+	err := c.cc.Invoke(ctx, WalletService_ListSupportedChains_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WalletServiceServer is the server API for WalletService service.
 // All implementations must embed UnimplementedWalletServiceServer
 // for forward compatibility.
 type WalletServiceServer interface {
 	GetSupportCoins(context.Context, *SupportCoinsRequest) (*SupportCoinsResponse, error)
 	GetWalletAddress(context.Context, *WalletAddressRequest) (*WalletAddressResponse, error)
+	ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error)
+	SignTransaction(context.Context, *SignTransactionRequest) (*SignTransactionResponse, error)
+	SignMessage(context.Context, *SignMessageRequest) (*SignatureResponse, error)
+	SignTypedData(context.Context, *SignTypedDataRequest) (*SignatureResponse, error)
+	SignPsbt(context.Context, *SignPsbtRequest) (*SignPsbtResponse, error)
+	BatchCreateAddresses(*BatchCreateAddressesRequest, grpc.ServerStreamingServer[BatchCreateAddressesResponse]) error
+	ListSupportedChains(context.Context, *ListSupportedChainsRequest) (*ListSupportedChainsResponse, error)
 	mustEmbedUnimplementedWalletServiceServer()
 }
 
@@ -81,6 +181,27 @@ func (UnimplementedWalletServiceServer) GetSupportCoins(context.Context, *Suppor
 func (UnimplementedWalletServiceServer) GetWalletAddress(context.Context, *WalletAddressRequest) (*WalletAddressResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetWalletAddress not implemented")
 }
+func (UnimplementedWalletServiceServer) ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedWalletServiceServer) SignTransaction(context.Context, *SignTransactionRequest) (*SignTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignTransaction not implemented")
+}
+func (UnimplementedWalletServiceServer) SignMessage(context.Context, *SignMessageRequest) (*SignatureResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignMessage not implemented")
+}
+func (UnimplementedWalletServiceServer) SignTypedData(context.Context, *SignTypedDataRequest) (*SignatureResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignTypedData not implemented")
+}
+func (UnimplementedWalletServiceServer) SignPsbt(context.Context, *SignPsbtRequest) (*SignPsbtResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignPsbt not implemented")
+}
+func (UnimplementedWalletServiceServer) BatchCreateAddresses(*BatchCreateAddressesRequest, grpc.ServerStreamingServer[BatchCreateAddressesResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BatchCreateAddresses not implemented")
+}
+func (UnimplementedWalletServiceServer) ListSupportedChains(context.Context, *ListSupportedChainsRequest) (*ListSupportedChainsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSupportedChains not implemented")
+}
 func (UnimplementedWalletServiceServer) mustEmbedUnimplementedWalletServiceServer() {}
 func (UnimplementedWalletServiceServer) testEmbeddedByValue()                       {}
 
@@ -138,6 +259,125 @@ func _WalletService_GetWalletAddress_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WalletService_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_ListKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ListKeys(ctx, req.(*ListKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_SignTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).SignTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_SignTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).SignTransaction(ctx, req.(*SignTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_SignMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).SignMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_SignMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).SignMessage(ctx, req.(*SignMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_SignTypedData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignTypedDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).SignTypedData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_SignTypedData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).SignTypedData(ctx, req.(*SignTypedDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_SignPsbt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignPsbtRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).SignPsbt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_SignPsbt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).SignPsbt(ctx, req.(*SignPsbtRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_BatchCreateAddresses_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BatchCreateAddressesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WalletServiceServer).BatchCreateAddresses(m, &grpc.GenericServerStream[BatchCreateAddressesRequest, BatchCreateAddressesResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WalletService_BatchCreateAddressesServer = grpc.ServerStreamingServer[BatchCreateAddressesResponse]
+
+func _WalletService_ListSupportedChains_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSupportedChainsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ListSupportedChains(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletService_ListSupportedChains_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ListSupportedChains(ctx, req.(*ListSupportedChainsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // WalletService_ServiceDesc is the grpc.ServiceDesc for WalletService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,7 +393,37 @@ var WalletService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "getWalletAddress",
 			Handler:    _WalletService_GetWalletAddress_Handler,
 		},
+		{
+			MethodName: "listKeys",
+			Handler:    _WalletService_ListKeys_Handler,
+		},
+		{
+			MethodName: "signTransaction",
+			Handler:    _WalletService_SignTransaction_Handler,
+		},
+		{
+			MethodName: "signMessage",
+			Handler:    _WalletService_SignMessage_Handler,
+		},
+		{
+			MethodName: "signTypedData",
+			Handler:    _WalletService_SignTypedData_Handler,
+		},
+		{
+			MethodName: "signPsbt",
+			Handler:    _WalletService_SignPsbt_Handler,
+		},
+		{
+			MethodName: "listSupportedChains",
+			Handler:    _WalletService_ListSupportedChains_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "batchCreateAddresses",
+			Handler:       _WalletService_BatchCreateAddresses_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "protobuf/wallet.proto",
 }
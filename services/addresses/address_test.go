@@ -25,3 +25,52 @@ func TestPublicKeyToAddress(t *testing.T) {
 	}
 	fmt.Println("address==", address)
 }
+
+// TestPublicKeyToBitcoinAddress checks the Bitcoin address derivation against
+// the BIP-173 (generator point) and BIP-86 (m/86'/0'/0'/0/0 of the "abandon ... about"
+// mnemonic) test vectors.
+func TestPublicKeyToBitcoinAddress(t *testing.T) {
+	tests := []struct {
+		publicKey   string
+		addressType string
+		network     string
+		address     string
+	}{
+		{"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", AddressTypeP2PKH, "MainNet", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+		{"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", AddressTypeP2PKH, "TestNet", "mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r"},
+		{"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", AddressTypeP2SHP2WPKH, "MainNet", "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN"},
+		{"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", AddressTypeP2SHP2WPKH, "TestNet", "2NAUYAHhujozruyzpsFRP63mbrdaU5wnEpN"},
+		{"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", AddressTypeP2WPKH, "MainNet", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", AddressTypeP2WPKH, "TestNet", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+		{"02cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115", AddressTypeP2TR, "MainNet", "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+		{"02cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115", AddressTypeP2TR, "TestNet", "tb1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqp3mvzv"},
+	}
+	for _, tt := range tests {
+		address, err := PublicKeyToBitcoinAddress(tt.publicKey, tt.addressType, tt.network)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.addressType, tt.network, err)
+		}
+		if address != tt.address {
+			t.Errorf("%s %s: got %s, want %s", tt.addressType, tt.network, address, tt.address)
+		}
+	}
+}
+
+func TestCreateBitcoinAddress(t *testing.T) {
+	for _, addressType := range []string{AddressTypeP2PKH, AddressTypeP2SHP2WPKH, AddressTypeP2WPKH, AddressTypeP2TR} {
+		address, err := CreateBitcoinAddress(addressType, "TestNet")
+		if err != nil {
+			t.Fatalf("%s: %v", addressType, err)
+		}
+		derived, err := PublicKeyToBitcoinAddress(address.PublicKey, addressType, "TestNet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if derived != address.Address {
+			t.Errorf("%s: got %s, want %s", addressType, derived, address.Address)
+		}
+	}
+	if _, err := CreateBitcoinAddress("p2wsh", "MainNet"); err == nil {
+		t.Error("expected unsupported address type to fail")
+	}
+}
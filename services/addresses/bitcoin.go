@@ -0,0 +1,135 @@
+package addresses
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	AddressTypeP2PKH      = "p2pkh"
+	AddressTypeP2SHP2WPKH = "p2sh-p2wpkh"
+	AddressTypeP2WPKH     = "p2wpkh"
+	AddressTypeP2TR       = "p2tr"
+
+	// DefaultBitcoinAddressType is used when a request does not name an address type.
+	DefaultBitcoinAddressType = AddressTypeP2WPKH
+)
+
+// IsBitcoinAddressType reports whether addressType is one of the supported
+// Bitcoin address types.
+func IsBitcoinAddressType(addressType string) bool {
+	switch addressType {
+	case AddressTypeP2PKH, AddressTypeP2SHP2WPKH, AddressTypeP2WPKH, AddressTypeP2TR:
+		return true
+	default:
+		return false
+	}
+}
+
+type BtcAddress struct {
+	PrivateKey  string `json:"private_key"`
+	PublicKey   string `json:"public_key"`
+	Address     string `json:"address"`
+	AddressType string `json:"address_type"`
+}
+
+// BitcoinNetParams returns the chain parameters for a network name.
+//
+// The name is matched case-insensitively against mainnet, testnet (or
+// testnet3), regtest and signet, so the MainNet and TestNet networks of the
+// default chain registry resolve as well.
+func BitcoinNetParams(network string) (*chaincfg.Params, error) {
+	switch strings.ToLower(network) {
+	case "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet", "testnet3":
+		return &chaincfg.TestNet3Params, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	case "signet":
+		return &chaincfg.SigNetParams, nil
+	default:
+		return nil, fmt.Errorf("unsupported bitcoin network %q", network)
+	}
+}
+
+// CreateBitcoinAddress generates a new secp256k1 key pair and derives the
+// Bitcoin address of the requested type on the requested network.
+//
+// The public key is returned in its 33 byte compressed form, which is the
+// form committed to by every supported address type.
+func CreateBitcoinAddress(addressType, network string) (*BtcAddress, error) {
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	publicKey := hex.EncodeToString(crypto.CompressPubkey(&prvKey.PublicKey))
+	address, err := PublicKeyToBitcoinAddress(publicKey, addressType, network)
+	if err != nil {
+		return nil, err
+	}
+	return &BtcAddress{
+		PrivateKey:  hex.EncodeToString(crypto.FromECDSA(prvKey)),
+		PublicKey:   publicKey,
+		Address:     address,
+		AddressType: addressType,
+	}, nil
+}
+
+// PublicKeyToBitcoinAddress derives the Bitcoin address of the given type from a
+// hex encoded compressed or uncompressed secp256k1 public key.
+//
+// Segwit and taproot addresses always commit to the compressed key. Taproot
+// addresses use the BIP-86 key path only tweak, without a script tree.
+func PublicKeyToBitcoinAddress(publicKey, addressType, network string) (string, error) {
+	params, err := BitcoinNetParams(network)
+	if err != nil {
+		return "", err
+	}
+	publicKeyBytes, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return "", err
+	}
+	pubKey, err := btcec.ParsePubKey(publicKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	compressed := pubKey.SerializeCompressed()
+
+	var address btcutil.Address
+	switch addressType {
+	case AddressTypeP2PKH:
+		address, err = btcutil.NewAddressPubKeyHash(btcutil.Hash160(compressed), params)
+	case AddressTypeP2WPKH:
+		address, err = btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(compressed), params)
+	case AddressTypeP2SHP2WPKH:
+		var witnessAddress *btcutil.AddressWitnessPubKeyHash
+		witnessAddress, err = btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(compressed), params)
+		if err != nil {
+			return "", err
+		}
+		var redeemScript []byte
+		redeemScript, err = txscript.PayToAddrScript(witnessAddress)
+		if err != nil {
+			return "", err
+		}
+		address, err = btcutil.NewAddressScriptHash(redeemScript, params)
+	case AddressTypeP2TR:
+		outputKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+		address, err = btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), params)
+	default:
+		return "", fmt.Errorf("unsupported bitcoin address type %q", addressType)
+	}
+	if err != nil {
+		return "", err
+	}
+	return address.EncodeAddress(), nil
+}
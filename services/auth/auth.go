@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+)
+
+// tokenBytes is the amount of randomness in a consumer token.
+const tokenBytes = 32
+
+// Scopes a token can be granted.
+const (
+	ScopeAddressCreate = "address:create"
+	ScopeKeysRead      = "keys:read"
+	ScopeSign          = "sign"
+)
+
+// AllScopes lists every scope, in the order they are stored.
+var AllScopes = []string{ScopeAddressCreate, ScopeKeysRead, ScopeSign}
+
+var (
+	ErrTokenRequired = errors.New("consumer token is required")
+	ErrInvalidToken  = errors.New("invalid consumer token")
+	ErrTokenRevoked  = errors.New("consumer token has been revoked")
+	ErrUnknownScope  = errors.New("unknown scope")
+)
+
+// Credential is the identity a consumer token resolves to.
+type Credential struct {
+	TokenId    uuid.UUID
+	BusinessId string
+	Scopes     []string
+}
+
+// HasScope reports whether the credential was granted scope.
+func (c *Credential) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// ParseScopes splits a comma separated scope list and rejects unknown scopes.
+func ParseScopes(scopes string) ([]string, error) {
+	var out []string
+	for _, scope := range strings.Split(scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !slices.Contains(AllScopes, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+		if !slices.Contains(out, scope) {
+			out = append(out, scope)
+		}
+	}
+	return out, nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash under which a token is
+// stored, so a leaked tokens table does not leak usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueToken generates a new consumer token for a business id and stores its
+// hash. The same token is accepted as consumer_token by the rpc service and
+// as API key by the rest api. The plain token is only returned here and
+// cannot be recovered later.
+//
+// Parameters:
+//   - tokens: The tokens table to store the token in.
+//   - businessId: The business the token is bound to.
+//   - scopes: The scopes granted to the token, see AllScopes.
+//
+// Returns:
+//   - string: The plain consumer token to hand to the business.
+//   - *database.Tokens: The stored token row.
+//   - error: An error if the business id is empty, a scope is unknown or the
+//     token cannot be stored.
+func IssueToken(tokens database.TokensDB, businessId string, scopes []string) (string, *database.Tokens, error) {
+	if businessId == "" {
+		return "", nil, errors.New("business_id is required")
+	}
+	scopes, err := ParseScopes(strings.Join(scopes, ","))
+	if err != nil {
+		return "", nil, err
+	}
+	raw := make([]byte, tokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(raw)
+	row := &database.Tokens{
+		GUID:       uuid.New(),
+		TokenHash:  HashToken(token),
+		BusinessId: businessId,
+		Scopes:     strings.Join(scopes, ","),
+		Timestamp:  uint64(time.Now().Unix()),
+	}
+	if err := tokens.StoreToken(row); err != nil {
+		return "", nil, err
+	}
+	return token, row, nil
+}
+
+// Authenticator resolves consumer tokens to the business they were issued to.
+type Authenticator struct {
+	tokens database.TokensView
+}
+
+func NewAuthenticator(tokens database.TokensView) *Authenticator {
+	return &Authenticator{tokens: tokens}
+}
+
+// Authenticate looks up a consumer token.
+//
+// Parameters:
+//   - token: The plain consumer token presented by the caller.
+//
+// Returns:
+//   - *Credential: The token id, business id and scopes the token is bound to.
+//   - error: ErrTokenRequired, ErrInvalidToken or ErrTokenRevoked if the token
+//     cannot be used, or the lookup error.
+func (a *Authenticator) Authenticate(token string) (*Credential, error) {
+	if token == "" {
+		return nil, ErrTokenRequired
+	}
+	row, err := a.tokens.QueryTokenByHash(HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, ErrInvalidToken
+	}
+	if row.Revoked {
+		return nil, ErrTokenRevoked
+	}
+	scopes, err := ParseScopes(row.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{TokenId: row.GUID, BusinessId: row.BusinessId, Scopes: scopes}, nil
+}
+
+// IsUnauthenticated reports whether err means the caller presented no usable
+// token, as opposed to the lookup failing.
+func IsUnauthenticated(err error) bool {
+	return errors.Is(err, ErrTokenRequired) ||
+		errors.Is(err, ErrInvalidToken) ||
+		errors.Is(err, ErrTokenRevoked)
+}
+
+type credentialKey struct{}
+
+// NewContext returns a copy of ctx carrying the caller's credential.
+func NewContext(ctx context.Context, cred *Credential) context.Context {
+	return context.WithValue(ctx, credentialKey{}, cred)
+}
+
+// FromContext returns the credential stored in ctx by NewContext.
+func FromContext(ctx context.Context) (*Credential, bool) {
+	cred, ok := ctx.Value(credentialKey{}).(*Credential)
+	return cred, ok
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+)
+
+// memTokensDB is an in-memory database.TokensDB keyed by token hash.
+type memTokensDB struct {
+	tokens map[string]*database.Tokens
+}
+
+func (m *memTokensDB) StoreToken(token *database.Tokens) error {
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (m *memTokensDB) RevokeToken(guid uuid.UUID) (bool, error) {
+	for _, token := range m.tokens {
+		if token.GUID == guid {
+			token.Revoked = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memTokensDB) QueryTokenByHash(tokenHash string) (*database.Tokens, error) {
+	return m.tokens[tokenHash], nil
+}
+
+func (m *memTokensDB) QueryTokensByBusId(busId string) ([]database.Tokens, error) {
+	var tokenList []database.Tokens
+	for _, token := range m.tokens {
+		if token.BusinessId == busId {
+			tokenList = append(tokenList, *token)
+		}
+	}
+	return tokenList, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	db := &memTokensDB{tokens: make(map[string]*database.Tokens)}
+	token, row, err := IssueToken(db, "merchant-1", []string{ScopeAddressCreate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.TokenHash == token {
+		t.Fatal("plain token stored")
+	}
+	a := NewAuthenticator(db)
+
+	cred, err := a.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.BusinessId != "merchant-1" || cred.TokenId != row.GUID {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+	if !cred.HasScope(ScopeAddressCreate) || cred.HasScope(ScopeSign) {
+		t.Fatalf("unexpected scopes %v", cred.Scopes)
+	}
+
+	if _, err := a.Authenticate(""); !errors.Is(err, ErrTokenRequired) {
+		t.Fatalf("got %v, want ErrTokenRequired", err)
+	}
+	if _, err := a.Authenticate("unknown"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got %v, want ErrInvalidToken", err)
+	}
+	if _, err := db.RevokeToken(row.GUID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("got %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes(" sign,keys:read,,sign ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scopes) != 2 || scopes[0] != ScopeSign || scopes[1] != ScopeKeysRead {
+		t.Fatalf("got %v", scopes)
+	}
+	if _, err := ParseScopes("sign,admin"); !errors.Is(err, ErrUnknownScope) {
+		t.Fatalf("got %v, want ErrUnknownScope", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatal("credential in empty context")
+	}
+	cred := &Credential{BusinessId: "merchant-1"}
+	got, ok := FromContext(NewContext(context.Background(), cred))
+	if !ok || got != cred {
+		t.Fatal("credential not returned")
+	}
+}
@@ -0,0 +1,408 @@
+package chains
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+	"github.com/qiaopengjun5162/go-rpc-service/services/addresses"
+)
+
+const (
+	Bitcoin  = "Bitcoin"
+	Ethereum = "Ethereum"
+
+	MainNet = "MainNet"
+	TestNet = "TestNet"
+)
+
+var (
+	ErrUnsupportedChain   = errors.New("unsupported chain")
+	ErrUnsupportedNetwork = errors.New("unsupported network")
+)
+
+// Chain is a supported chain with the networks it can be used on.
+type Chain struct {
+	// Name is the canonical chain name used throughout the service.
+	Name string
+	// Aliases are alternative names accepted for the chain.
+	Aliases []string
+	// Curve is the elliptic curve of the chain's keys.
+	Curve string
+	// AddressFormats are the address formats issued for the chain.
+	AddressFormats []string
+	// DerivationPath is the default HD derivation path of the chain, used by
+	// networks that do not set their own.
+	DerivationPath string
+	// Networks are the supported networks.
+	Networks []Network
+}
+
+// Network is a supported network of a chain.
+type Network struct {
+	Name    string
+	Aliases []string
+	// ChainId is the chain id of EVM networks, zero elsewhere.
+	ChainId uint64
+	// Params names the Bitcoin chain parameters of the network: mainnet,
+	// testnet3, regtest or signet. It is empty elsewhere.
+	Params string
+	// DerivationPath is the path HD keys of the network are derived under,
+	// up to the address index. For Bitcoin it is the path of the default
+	// address format; the purpose level follows the requested format.
+	DerivationPath string
+}
+
+// Registry resolves the chain and network names sent by clients to their
+// canonical names. Names and aliases are matched case-insensitively, so
+// "mainnet" and "MAINNET" both resolve to MainNet. It is
+// shared by the rest and rpc transports so both accept the same requests.
+type Registry struct {
+	chains []Chain
+	byName map[string]*Chain
+}
+
+// NewRegistry builds a registry of the given chains.
+//
+// Parameters:
+//   - chainList: The supported chains.
+//
+// Returns:
+//   - *Registry: The registry.
+//   - error: An error if a chain has no name or no networks, or if two chains,
+//     or two networks of a chain, share a name or alias.
+func NewRegistry(chainList []Chain) (*Registry, error) {
+	r := &Registry{
+		chains: chainList,
+		byName: make(map[string]*Chain),
+	}
+	for i := range r.chains {
+		chain := &r.chains[i]
+		if chain.Name == "" {
+			return nil, errors.New("chain name is required")
+		}
+		if len(chain.Networks) == 0 {
+			return nil, fmt.Errorf("chain %s has no networks", chain.Name)
+		}
+		for _, name := range append([]string{chain.Name}, chain.Aliases...) {
+			key := strings.ToLower(name)
+			if _, ok := r.byName[key]; ok {
+				return nil, fmt.Errorf("duplicate chain name %q", name)
+			}
+			r.byName[key] = chain
+		}
+		seen := make(map[string]bool)
+		for _, network := range chain.Networks {
+			if network.Name == "" {
+				return nil, fmt.Errorf("network name is required for chain %s", chain.Name)
+			}
+			for _, name := range append([]string{network.Name}, network.Aliases...) {
+				key := strings.ToLower(name)
+				if seen[key] {
+					return nil, fmt.Errorf("duplicate network name %q for chain %s", name, chain.Name)
+				}
+				seen[key] = true
+			}
+		}
+	}
+	return r, nil
+}
+
+// FromConfig builds the registry of the chains listed in the config file.
+// The default registry is returned when the file lists no chains.
+//
+// Chains are implemented by name, so every entry must be one of the built-in
+// chains, on the secp256k1 curve, with a subset of the built-in address
+// formats that keeps the chain's default format. Networks are free: Ethereum
+// networks need a chain id, which EIP-712 domains are checked against, and
+// Bitcoin networks need chain parameters, which default to those of the
+// built-in network of the same name. Derivation paths are taken
+// from the network, then from the chain, then from the built-in network of
+// the same name or the built-in chain.
+//
+// Parameters:
+//   - chainList: The chains read from the config file.
+//
+// Returns:
+//   - *Registry: The registry.
+//   - error: An error if an entry cannot be served or the chains are not a
+//     valid registry.
+func FromConfig(chainList []config.ChainConfig) (*Registry, error) {
+	if len(chainList) == 0 {
+		return DefaultRegistry(), nil
+	}
+	builtin := DefaultRegistry()
+	out := make([]Chain, 0, len(chainList))
+	for _, c := range chainList {
+		networks := make([]Network, 0, len(c.Networks))
+		for _, n := range c.Networks {
+			networks = append(networks, Network{
+				Name:           n.Name,
+				Aliases:        n.Aliases,
+				ChainId:        n.ChainId,
+				Params:         n.Params,
+				DerivationPath: n.DerivationPath,
+			})
+		}
+		chain, err := builtin.servable(Chain{
+			Name:           c.Name,
+			Aliases:        c.Aliases,
+			Curve:          c.Curve,
+			AddressFormats: c.AddressFormats,
+			DerivationPath: c.DerivationPath,
+			Networks:       networks,
+		})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chain)
+	}
+	return NewRegistry(out)
+}
+
+// Curve is the elliptic curve of every supported chain.
+const Curve = "secp256k1"
+
+// defaultAddressFormats is the format issued for each chain when a request
+// does not name one.
+var defaultAddressFormats = map[string]string{
+	Bitcoin:  "p2wpkh",
+	Ethereum: "eip55",
+}
+
+// servable checks a configured chain against the built-in chain of the same
+// name and fills in the defaults it leaves empty.
+func (r *Registry) servable(c Chain) (Chain, error) {
+	builtin, ok := r.byName[strings.ToLower(c.Name)]
+	if !ok || builtin.Name != c.Name {
+		return Chain{}, fmt.Errorf("%w: %q, the service only implements %s", ErrUnsupportedChain, c.Name, strings.Join(r.names(), ", "))
+	}
+	if c.Curve == "" {
+		c.Curve = Curve
+	}
+	if c.Curve != Curve {
+		return Chain{}, fmt.Errorf("chain %s: unsupported curve %q, want %s", c.Name, c.Curve, Curve)
+	}
+	if len(c.AddressFormats) == 0 {
+		c.AddressFormats = builtin.AddressFormats
+	}
+	for _, format := range c.AddressFormats {
+		if !slices.Contains(builtin.AddressFormats, format) {
+			return Chain{}, fmt.Errorf("chain %s: unsupported address format %q", c.Name, format)
+		}
+	}
+	if format := defaultAddressFormats[c.Name]; !slices.Contains(c.AddressFormats, format) {
+		return Chain{}, fmt.Errorf("chain %s: address formats must include the default %s", c.Name, format)
+	}
+	chainPath := c.DerivationPath
+	if c.DerivationPath == "" {
+		c.DerivationPath = builtin.DerivationPath
+	}
+	if err := checkDerivationPath(c.DerivationPath); err != nil {
+		return Chain{}, fmt.Errorf("chain %s: %w", c.Name, err)
+	}
+	networks := make([]Network, 0, len(c.Networks))
+	for _, network := range c.Networks {
+		var known *Network
+		if name, err := resolveNetwork(builtin, network.Name); err == nil && name == network.Name {
+			for i := range builtin.Networks {
+				if builtin.Networks[i].Name == name {
+					known = &builtin.Networks[i]
+				}
+			}
+		}
+		if network.DerivationPath == "" {
+			network.DerivationPath = c.DerivationPath
+			if chainPath == "" && known != nil {
+				network.DerivationPath = known.DerivationPath
+			}
+		}
+		if err := checkDerivationPath(network.DerivationPath); err != nil {
+			return Chain{}, fmt.Errorf("chain %s: network %s: %w", c.Name, network.Name, err)
+		}
+		switch c.Name {
+		case Ethereum:
+			if network.ChainId == 0 {
+				return Chain{}, fmt.Errorf("chain %s: network %s needs a chain_id", c.Name, network.Name)
+			}
+			if network.Params != "" {
+				return Chain{}, fmt.Errorf("chain %s: network %s cannot have params", c.Name, network.Name)
+			}
+		case Bitcoin:
+			if network.ChainId != 0 {
+				return Chain{}, fmt.Errorf("chain %s: network %s cannot have a chain_id", c.Name, network.Name)
+			}
+			if network.Params == "" && known != nil {
+				network.Params = known.Params
+			}
+			if _, err := addresses.BitcoinNetParams(network.Params); err != nil {
+				return Chain{}, fmt.Errorf("chain %s: network %s needs params of mainnet, testnet3, regtest or signet: %w", c.Name, network.Name, err)
+			}
+		}
+		networks = append(networks, network)
+	}
+	c.Networks = networks
+	return c, nil
+}
+
+// checkDerivationPath checks that path is a BIP-32 path below the master key.
+// The address index is appended to it, so it must leave room for one level.
+func checkDerivationPath(path string) error {
+	levels, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return fmt.Errorf("invalid derivation path %q: %w", path, err)
+	}
+	if len(levels) == 0 || len(levels) > 254 {
+		return fmt.Errorf("invalid derivation path %q", path)
+	}
+	return nil
+}
+
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.chains))
+	for _, c := range r.Chains() {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// DefaultRegistry returns the registry of the chains supported out of the box:
+// Bitcoin and Ethereum on main net and test net. It matches the chains of the
+// config.yml shipped with the service.
+func DefaultRegistry() *Registry {
+	r, err := NewRegistry([]Chain{
+		{
+			Name:           Bitcoin,
+			Aliases:        []string{"btc"},
+			Curve:          Curve,
+			AddressFormats: []string{"p2pkh", "p2sh-p2wpkh", "p2wpkh", "p2tr"},
+			DerivationPath: "m/84'/0'/0'/0",
+			Networks: []Network{
+				{Name: MainNet, Aliases: []string{"main"}, Params: "mainnet", DerivationPath: "m/84'/0'/0'/0"},
+				{Name: TestNet, Aliases: []string{"test", "testnet3"}, Params: "testnet3", DerivationPath: "m/84'/1'/0'/0"},
+			},
+		},
+		{
+			Name:           Ethereum,
+			Aliases:        []string{"eth"},
+			Curve:          Curve,
+			AddressFormats: []string{"eip55"},
+			DerivationPath: "m/44'/60'/0'/0",
+			Networks: []Network{
+				{Name: MainNet, Aliases: []string{"main"}, ChainId: 1, DerivationPath: "m/44'/60'/0'/0"},
+				{Name: TestNet, Aliases: []string{"test", "sepolia"}, ChainId: 11155111, DerivationPath: "m/44'/60'/0'/0"},
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Resolve returns the canonical chain and network names of a request.
+//
+// Parameters:
+//   - chain: The chain name or alias sent by the client.
+//   - network: The network name or alias sent by the client.
+//
+// Returns:
+//   - string: The canonical chain name.
+//   - string: The canonical network name.
+//   - error: ErrUnsupportedChain or ErrUnsupportedNetwork.
+func (r *Registry) Resolve(chain, network string) (string, string, error) {
+	c, err := r.chain(chain)
+	if err != nil {
+		return "", "", err
+	}
+	n, err := resolveNetwork(c, network)
+	if err != nil {
+		return "", "", err
+	}
+	return c.Name, n, nil
+}
+
+// Chain returns the chain registered under a name or alias.
+//
+// Returns:
+//   - Chain: The chain.
+//   - error: ErrUnsupportedChain.
+func (r *Registry) Chain(name string) (Chain, error) {
+	c, err := r.chain(name)
+	if err != nil {
+		return Chain{}, err
+	}
+	return *c, nil
+}
+
+// Network returns a network of a chain, both given by name or alias. The
+// chain id of Ethereum networks, the chain parameters of Bitcoin networks and
+// the derivation path of HD keys come from here.
+//
+// Returns:
+//   - Network: The network.
+//   - error: ErrUnsupportedChain or ErrUnsupportedNetwork.
+func (r *Registry) Network(chain, network string) (Network, error) {
+	c, err := r.chain(chain)
+	if err != nil {
+		return Network{}, err
+	}
+	name, err := resolveNetwork(c, network)
+	if err != nil {
+		return Network{}, err
+	}
+	for _, n := range c.Networks {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return Network{}, fmt.Errorf("%w: %q for %s", ErrUnsupportedNetwork, network, c.Name)
+}
+
+// ResolveNetwork returns the canonical network name for a network of the
+// given canonical chain.
+func (r *Registry) ResolveNetwork(chain, network string) (string, error) {
+	_, n, err := r.Resolve(chain, network)
+	return n, err
+}
+
+// Supports reports whether the chain and network, or their aliases, are supported.
+func (r *Registry) Supports(chain, network string) bool {
+	_, _, err := r.Resolve(chain, network)
+	return err == nil
+}
+
+// Chains returns the supported chains ordered by name.
+func (r *Registry) Chains() []Chain {
+	out := append([]Chain(nil), r.chains...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (r *Registry) chain(name string) (*Chain, error) {
+	c, ok := r.byName[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedChain, name)
+	}
+	return c, nil
+}
+
+func resolveNetwork(c *Chain, name string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	for _, network := range c.Networks {
+		if strings.ToLower(network.Name) == key {
+			return network.Name, nil
+		}
+		for _, alias := range network.Aliases {
+			if strings.ToLower(alias) == key {
+				return network.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %q for %s", ErrUnsupportedNetwork, name, c.Name)
+}
@@ -0,0 +1,141 @@
+package chains
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+)
+
+func TestResolve(t *testing.T) {
+	r := DefaultRegistry()
+	tests := []struct {
+		chain, network     string
+		wantChain, wantNet string
+		err                error
+	}{
+		{"Bitcoin", "MainNet", Bitcoin, MainNet, nil},
+		{"bitcoin", "mainnet", Bitcoin, MainNet, nil},
+		{"ETH", "Sepolia", Ethereum, TestNet, nil},
+		{" Ethereum ", "TESTNET", Ethereum, TestNet, nil},
+		{"Dogecoin", "MainNet", "", "", ErrUnsupportedChain},
+		{"Bitcoin", "sepolia", "", "", ErrUnsupportedNetwork},
+		{"", "MainNet", "", "", ErrUnsupportedChain},
+	}
+	for _, tt := range tests {
+		chain, network, err := r.Resolve(tt.chain, tt.network)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s/%s: got error %v, want %v", tt.chain, tt.network, err, tt.err)
+			continue
+		}
+		if chain != tt.wantChain || network != tt.wantNet {
+			t.Errorf("%s/%s: got %s/%s, want %s/%s", tt.chain, tt.network, chain, network, tt.wantChain, tt.wantNet)
+		}
+	}
+}
+
+func TestNewRegistryDuplicates(t *testing.T) {
+	_, err := NewRegistry([]Chain{
+		{Name: Bitcoin, Aliases: []string{"btc"}},
+		{Name: "Bitcoin Cash", Aliases: []string{"BTC"}},
+	})
+	if err == nil {
+		t.Fatal("duplicate alias accepted")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	r, err := FromConfig([]config.ChainConfig{{
+		Name:           Ethereum,
+		Aliases:        []string{"ether"},
+		AddressFormats: []string{"eip55"},
+		Networks:       []config.NetworkConfig{{Name: TestNet, Aliases: []string{"holesky"}, ChainId: 17000}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain, network, err := r.Resolve("ETHER", "holesky"); err != nil || chain != Ethereum || network != TestNet {
+		t.Fatalf("got %s/%s %v", chain, network, err)
+	}
+	if r.Supports(Bitcoin, MainNet) || r.Supports(Ethereum, MainNet) {
+		t.Fatal("configured registry still supports the unlisted chains and networks")
+	}
+	if n, err := r.Network("ether", "holesky"); err != nil || n.ChainId != 17000 {
+		t.Fatalf("got chain id %d, %v", n.ChainId, err)
+	}
+	if c, err := r.Chain(Ethereum); err != nil || c.Curve != "secp256k1" || c.DerivationPath != "m/44'/60'/0'/0" {
+		t.Fatalf("defaults not filled in: %+v, %v", c, err)
+	}
+
+	r, err = FromConfig(nil)
+	if err != nil || !r.Supports(Bitcoin, MainNet) {
+		t.Fatalf("empty config does not fall back to the default registry: %v", err)
+	}
+}
+
+// TestFromConfigNetworks adds networks and derivation paths the built-in
+// registry does not have.
+func TestFromConfigNetworks(t *testing.T) {
+	r, err := FromConfig([]config.ChainConfig{
+		{Name: Bitcoin, Networks: []config.NetworkConfig{
+			{Name: TestNet},
+			{Name: "Signet", Params: "signet", DerivationPath: "m/84'/1'/1'/0"},
+			{Name: "Regtest", Params: "regtest"},
+		}},
+		{Name: Ethereum, DerivationPath: "m/44'/60'/1'/0", Networks: []config.NetworkConfig{
+			{Name: MainNet, ChainId: 1},
+			{Name: "Holesky", ChainId: 17000, DerivationPath: "m/44'/1'/0'/0"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		chain, network, params, path string
+		chainId                      uint64
+	}{
+		{Bitcoin, TestNet, "testnet3", "m/84'/1'/0'/0", 0},
+		{Bitcoin, "signet", "signet", "m/84'/1'/1'/0", 0},
+		{Bitcoin, "Regtest", "regtest", "m/84'/0'/0'/0", 0},
+		{Ethereum, MainNet, "", "m/44'/60'/1'/0", 1},
+		{Ethereum, "holesky", "", "m/44'/1'/0'/0", 17000},
+	} {
+		n, err := r.Network(tt.chain, tt.network)
+		if err != nil {
+			t.Errorf("%s/%s: %v", tt.chain, tt.network, err)
+			continue
+		}
+		if n.Params != tt.params || n.DerivationPath != tt.path || n.ChainId != tt.chainId {
+			t.Errorf("%s/%s: got %+v", tt.chain, tt.network, n)
+		}
+	}
+}
+
+func TestFromConfigRejectsUnservable(t *testing.T) {
+	btcMain := []config.NetworkConfig{{Name: MainNet}}
+	ethMain := []config.NetworkConfig{{Name: MainNet, ChainId: 1}}
+	tests := []struct {
+		name  string
+		chain config.ChainConfig
+	}{
+		{"unknown chain", config.ChainConfig{Name: "Polygon", Networks: []config.NetworkConfig{{Name: "Amoy", ChainId: 80002}}}},
+		{"alias as name", config.ChainConfig{Name: "btc", Networks: btcMain}},
+		{"no networks", config.ChainConfig{Name: Bitcoin}},
+		{"network without params", config.ChainConfig{Name: Bitcoin, Networks: []config.NetworkConfig{{Name: "Signet"}}}},
+		{"unknown params", config.ChainConfig{Name: Bitcoin, Networks: []config.NetworkConfig{{Name: "Testnet4", Params: "testnet4"}}}},
+		{"ethereum params", config.ChainConfig{Name: Ethereum, Networks: []config.NetworkConfig{{Name: MainNet, ChainId: 1, Params: "mainnet"}}}},
+		{"network alias as name", config.ChainConfig{Name: Bitcoin, Networks: []config.NetworkConfig{{Name: "main"}}}},
+		{"curve", config.ChainConfig{Name: Bitcoin, Curve: "ed25519", Networks: btcMain}},
+		{"derivation path", config.ChainConfig{Name: Ethereum, DerivationPath: "m/44'/sixty'", Networks: ethMain}},
+		{"network derivation path", config.ChainConfig{Name: Ethereum, Networks: []config.NetworkConfig{{Name: MainNet, ChainId: 1, DerivationPath: "44'/60'/x"}}}},
+		{"address format", config.ChainConfig{Name: Bitcoin, AddressFormats: []string{"p2wpkh", "p2wsh"}, Networks: btcMain}},
+		{"no default format", config.ChainConfig{Name: Bitcoin, AddressFormats: []string{"p2tr"}, Networks: btcMain}},
+		{"missing chain id", config.ChainConfig{Name: Ethereum, Networks: []config.NetworkConfig{{Name: MainNet}}}},
+		{"bitcoin chain id", config.ChainConfig{Name: Bitcoin, Networks: []config.NetworkConfig{{Name: MainNet, ChainId: 1}}}},
+	}
+	for _, tt := range tests {
+		if _, err := FromConfig([]config.ChainConfig{tt.chain}); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
@@ -1,13 +1,11 @@
 package client
 
 import (
-	"fmt"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
 )
 
-var errWalletHTTPError = errors.New("wallet http error")
-
 type Address struct {
 	PublicKey string
 	Address   string
@@ -27,35 +25,43 @@ type WalletClient interface {
 	//   - An error if any occurs during the process.
 	GetSupportCoins(chain, network string) (bool, error)
 
-	// GetWalletAddress returns the wallet address for the given chain and network.
+	// GetWalletAddress issues a wallet address for the given chain, network and business id.
 	// The response object contains the public key and address.
-	GetWalletAddress(chain, network string) (*Address, error)
+	GetWalletAddress(chain, network, businessId string) (*Address, error)
 }
 
 type Client struct {
 	client *resty.Client
 }
 
+// APIKeyHeader is the request header the API key is sent in.
+const APIKeyHeader = "X-API-Key"
+
 // NewWalletClient creates a new WalletClient that connects to the specified URL.
 //
 // Parameters:
 //   - url: The URL of the wallet service to connect to.
+//   - apiKey: The API key sent with every request, issued by the token command.
 //
 // Returns:
 //   - A pointer to a WalletClient object.
-func NewWalletClient(url string) *Client {
+func NewWalletClient(url, apiKey string) *Client {
 	client := resty.New()
 	client.SetBaseURL(url)
+	client.SetHeader(APIKeyHeader, apiKey)
+	client.SetError(&ErrorResponse{})
 	client.OnAfterResponse(func(c *resty.Client, r *resty.Response) error {
 		statusCode := r.StatusCode()
 		if statusCode >= 400 {
-			method := r.Request.Method
-			baseUrl := r.Request.URL
-			return fmt.Errorf("%d cannot %s %s: %w", statusCode, method, baseUrl, errWalletHTTPError)
+			walletErr := &Error{StatusCode: statusCode}
+			if errResp, ok := r.Error().(*ErrorResponse); ok {
+				walletErr.Code = errResp.Code
+				walletErr.Message = errResp.Message
+				walletErr.RequestId = errResp.RequestId
+			}
+			return walletErr
 		}
-		fmt.Println("baseUrl::", r.Request.Method)
-		fmt.Println("method::", r.Request.URL)
-		fmt.Println("method::", r.Request.QueryParam)
+		log.Debug("wallet response", "method", r.Request.Method, "url", r.Request.URL, "status", statusCode)
 		return nil
 	})
 	return &Client{
@@ -73,14 +79,15 @@ func NewWalletClient(url string) *Client {
 //
 // Returns:
 //   - A boolean indicating whether the chain and network are supported.
-//   - An error if any occurs during the process.
+//   - An error if any occurs during the process. Error responses of the service
+//     are returned as *Error.
 func (c *Client) GetSupportCoins(chain, network string) (bool, error) {
 	res, err := c.client.R().SetQueryParams(map[string]string{
 		"chain":   chain,
 		"network": network,
 	}).SetResult(&SupportChainResponse{}).Get("/api/v1/support_chain")
 	if err != nil {
-		return false, errors.New("support chain request fail")
+		return false, err
 	}
 	spt, ok := res.Result().(*SupportChainResponse)
 	if !ok {
@@ -89,23 +96,26 @@ func (c *Client) GetSupportCoins(chain, network string) (bool, error) {
 	return spt.Support, nil
 }
 
-// GetWalletAddress returns the wallet address for the given chain and network.
+// GetWalletAddress issues a wallet address for the given chain, network and business id.
 // The response object contains the public key and address.
 //
 // Parameters:
 //   - chain: The name of the blockchain to be checked.
 //   - network: The name of the network to be checked.
+//   - businessId: The business the address is issued to.
 //
 // Returns:
 //   - A pointer to an Address object containing the generated address and public key.
-//   - An error if any occurs during the process.
-func (c *Client) GetWalletAddress(chain, network string) (*Address, error) {
+//   - An error if any occurs during the process. Error responses of the service
+//     are returned as *Error.
+func (c *Client) GetWalletAddress(chain, network, businessId string) (*Address, error) {
 	res, err := c.client.R().SetQueryParams(map[string]string{
-		"chain":   chain,
-		"network": network,
+		"chain":       chain,
+		"network":     network,
+		"business_id": businessId,
 	}).SetResult(&WalletAddressResponse{}).Get("/api/v1/wallet_address")
 	if err != nil {
-		return nil, errors.New("wallet address request fail")
+		return nil, err
 	}
 	wap, ok := res.Result().(*WalletAddressResponse)
 	if !ok {
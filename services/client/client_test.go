@@ -1,7 +1,11 @@
 package client
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 )
 
@@ -9,7 +13,7 @@ import (
 //
 // It tests with a known supported chain and network.
 func TestSupportChain(t *testing.T) {
-	client := NewWalletClient("http://127.0.0.1:8970")
+	client := NewWalletClient("http://127.0.0.1:8970", os.Getenv("SIGNATURE_API_KEY"))
 	result, err := client.GetSupportCoins("Bitcoin", "MainNet")
 	if err != nil {
 		fmt.Println("Get support chain fail")
@@ -23,8 +27,8 @@ func TestSupportChain(t *testing.T) {
 // It tests with a known supported chain and network, and verifies that the
 // response contains a valid address and public key.
 func TestWalletAddress(t *testing.T) {
-	client := NewWalletClient("http://127.0.0.1:8970")
-	addressInfo, err := client.GetWalletAddress("Bitcoin", "MainNet")
+	client := NewWalletClient("http://127.0.0.1:8970", os.Getenv("SIGNATURE_API_KEY"))
+	addressInfo, err := client.GetWalletAddress("Ethereum", "MainNet", "merchant-1")
 	if err != nil {
 		fmt.Println("Get wallet address fail")
 		return
@@ -40,3 +44,27 @@ func TestWalletAddress(t *testing.T) {
 	"address": "0x00000000"
 }
 */
+
+// TestErrorResponse checks that error envelopes are decoded into *Error and
+// matched by the sentinel errors.
+func TestErrorResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":"validation_error","message":"unsupported chain","request_id":"req-1"}`))
+	}))
+	defer srv.Close()
+
+	client := NewWalletClient(srv.URL, "key")
+	_, err := client.GetWalletAddress("Dogecoin", "MainNet", "merchant-1")
+	var walletErr *Error
+	if !errors.As(err, &walletErr) {
+		t.Fatalf("got %v, want *Error", err)
+	}
+	if walletErr.StatusCode != http.StatusBadRequest || walletErr.Message != "unsupported chain" || walletErr.RequestId != "req-1" {
+		t.Fatalf("unexpected error %+v", walletErr)
+	}
+	if !errors.Is(err, ErrValidation) || errors.Is(err, ErrInternal) {
+		t.Fatalf("%v not matched as validation error", err)
+	}
+}
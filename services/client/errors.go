@@ -0,0 +1,70 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors matched by *Error with errors.Is, one per error code of the
+// wallet service.
+var (
+	ErrValidation   = errors.New("validation error")
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrInternal     = errors.New("internal error")
+)
+
+// codeErrors maps the error codes of the wallet service onto the sentinels.
+var codeErrors = map[string]error{
+	"validation_error": ErrValidation,
+	"not_found":        ErrNotFound,
+	"unauthorized":     ErrUnauthorized,
+	"forbidden":        ErrForbidden,
+	"internal_error":   ErrInternal,
+}
+
+// statusErrors classifies error responses without an error envelope, such as
+// gateway or timeout errors, by their HTTP status.
+var statusErrors = map[int]error{
+	http.StatusBadRequest:   ErrValidation,
+	http.StatusNotFound:     ErrNotFound,
+	http.StatusUnauthorized: ErrUnauthorized,
+	http.StatusForbidden:    ErrForbidden,
+}
+
+// Error is an error response of the wallet service.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestId  string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("wallet service error %d", e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestId != "" {
+		msg += " (request_id " + e.RequestId + ")"
+	}
+	return msg
+}
+
+// Is matches the sentinel error of the response's code, or of its HTTP
+// status if the response carried no code.
+func (e *Error) Is(target error) bool {
+	if err, ok := codeErrors[e.Code]; ok {
+		return err == target
+	}
+	if err, ok := statusErrors[e.StatusCode]; ok {
+		return err == target
+	}
+	return e.StatusCode >= http.StatusInternalServerError && target == ErrInternal
+}
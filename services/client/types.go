@@ -8,3 +8,10 @@ type WalletAddressResponse struct {
 	PublicKey string `json:"publicKey"`
 	Address   string `json:"address"`
 }
+
+// ErrorResponse is the error envelope of the wallet service.
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestId string `json:"request_id"`
+}
@@ -0,0 +1,68 @@
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// mnemonicEntropyBits gives 24 word mnemonics.
+const mnemonicEntropyBits = 256
+
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// NewMnemonic generates a new 24 word BIP-39 mnemonic.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// IsMnemonicValid reports whether mnemonic is a BIP-39 mnemonic with a valid
+// checksum.
+func IsMnemonicValid(mnemonic string) bool {
+	return bip39.IsMnemonicValid(mnemonic)
+}
+
+// DeriveKey derives the BIP-32 private key at the given path from a BIP-39
+// mnemonic, using an empty passphrase.
+//
+// Parameters:
+//   - mnemonic: The BIP-39 mnemonic of the wallet.
+//   - path: The derivation path, such as m/84'/0'/0'/0/0.
+//
+// Returns:
+//   - *ecdsa.PrivateKey: The derived secp256k1 private key.
+//   - error: ErrInvalidMnemonic, or an error if the path cannot be parsed or derived.
+func DeriveKey(mnemonic, path string) (*ecdsa.PrivateKey, error) {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	// The network parameters only affect how extended keys are serialized,
+	// which never happens here, so the main net parameters are used for every chain.
+	key, err := hdkeychain.NewMaster(bip39.NewSeed(mnemonic, ""), &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range derivationPath {
+		key, err = key.Derive(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	prvKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	return prvKey.ToECDSA(), nil
+}
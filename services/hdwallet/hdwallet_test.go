@@ -0,0 +1,59 @@
+package hdwallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/qiaopengjun5162/go-rpc-service/services/addresses"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// TestDeriveKey checks the derivation against the first receiving address of
+// the BIP-44 (Ethereum), BIP-84 and BIP-86 test vectors.
+func TestDeriveKey(t *testing.T) {
+	tests := []struct {
+		path        string
+		addressType string
+		address     string
+	}{
+		{"m/44'/60'/0'/0/0", "", "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		{"m/84'/0'/0'/0/0", addresses.AddressTypeP2WPKH, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{"m/86'/0'/0'/0/0", addresses.AddressTypeP2TR, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+	}
+	for _, tt := range tests {
+		prvKey, err := DeriveKey(testMnemonic, tt.path)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		var address string
+		if tt.addressType == "" {
+			address = crypto.PubkeyToAddress(prvKey.PublicKey).Hex()
+		} else {
+			publicKey := hex.EncodeToString(crypto.CompressPubkey(&prvKey.PublicKey))
+			address, err = addresses.PublicKeyToBitcoinAddress(publicKey, tt.addressType, "MainNet")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if address != tt.address {
+			t.Errorf("%s: got %s, want %s", tt.path, address, tt.address)
+		}
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words := len(strings.Fields(mnemonic)); words != 24 {
+		t.Fatalf("got %d words, want 24", words)
+	}
+	if _, err := DeriveKey("abandon about", "m/44'/60'/0'/0/0"); err != ErrInvalidMnemonic {
+		t.Fatalf("got %v, want ErrInvalidMnemonic", err)
+	}
+}
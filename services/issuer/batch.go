@@ -0,0 +1,91 @@
+package issuer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+)
+
+const (
+	// MaxBatchCount is the largest number of addresses one batch may create.
+	MaxBatchCount = 100_000
+	// BatchChunkSize is the number of keys generated, stored and reported at
+	// a time while creating a batch.
+	BatchChunkSize = 500
+)
+
+var ErrInvalidCount = errors.New("count must be between 1 and 100000")
+
+// IssueAddresses creates count random keys for the requested chain and
+// network, stores them for the business id and reports them chunk by chunk.
+//
+// Every chunk of BatchChunkSize keys is stored with a single batched insert
+// before it is passed to emit, so every reported address has its private key
+// stored. The context is checked before each chunk; a cancelled batch keeps
+// the chunks that were already stored and reported.
+//
+// Parameters:
+//   - ctx: A context.Context that cancels the batch.
+//   - req: A pointer to an AddressRequest object describing the business,
+//     chain, network and address type. HD derivation is not supported.
+//   - count: The number of addresses to create.
+//   - emit: Called with every stored chunk and the number of addresses
+//     created so far. An error from emit stops the batch.
+//
+// Returns:
+//   - error: An invalid request error, ErrInvalidCount, the context error if
+//     the batch was cancelled, or the storage or emit error.
+func (i *Issuer) IssueAddresses(ctx context.Context, req *AddressRequest, count int, emit func([]Address, int) error) error {
+	if req.BusinessId == "" {
+		return ErrBusinessIdRequired
+	}
+	if count <= 0 || count > MaxBatchCount {
+		return ErrInvalidCount
+	}
+	addressType, err := i.normalizeAddressType(req.Chain, req.Network, req.AddressType)
+	if err != nil {
+		return err
+	}
+	created := 0
+	for created < count {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := min(count-created, BatchChunkSize)
+		keyList := make([]database.Keys, 0, n)
+		chunk := make([]Address, 0, n)
+		for len(keyList) < n {
+			prvKey, err := crypto.GenerateKey()
+			if err != nil {
+				return err
+			}
+			pair, err := i.newKeyPair(prvKey, req.Chain, req.Network, addressType)
+			if err != nil {
+				return err
+			}
+			key, err := i.sealKey(req.BusinessId, req.Chain, req.Network, pair)
+			if err != nil {
+				return err
+			}
+			keyList = append(keyList, *key)
+			chunk = append(chunk, Address{
+				PublicKey:   pair.PublicKey,
+				Address:     pair.Address,
+				AddressType: pair.AddressType,
+			})
+		}
+		if err := i.db.Keys.StoreKeys(keyList, database.CreateBatchSize); err != nil {
+			return err
+		}
+		created += n
+		metrics.RecordAddresses(req.Chain, req.Network, "batch", n)
+		if err := emit(chunk, created); err != nil {
+			return err
+		}
+	}
+	return nil
+}
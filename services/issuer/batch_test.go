@@ -0,0 +1,119 @@
+package issuer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+var testBatchRequest = &AddressRequest{BusinessId: "merchant-1", Chain: chains.Ethereum, Network: chains.MainNet}
+
+func countKeys(t *testing.T, i *Issuer) int64 {
+	t.Helper()
+	_, total, err := i.db.Keys.QueryKeysByBusId(testBatchRequest.BusinessId, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
+
+func TestIssueAddressesChunks(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{})
+	count := 2*BatchChunkSize + 3
+	var sizes, totals []int
+	seen := make(map[string]bool)
+	err := i.IssueAddresses(context.Background(), testBatchRequest, count, func(chunk []Address, created int) error {
+		sizes = append(sizes, len(chunk))
+		totals = append(totals, created)
+		for _, address := range chunk {
+			seen[address.Address] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != BatchChunkSize || sizes[1] != BatchChunkSize || sizes[2] != 3 {
+		t.Fatalf("got chunks of %v", sizes)
+	}
+	if totals[0] != BatchChunkSize || totals[1] != 2*BatchChunkSize || totals[2] != count {
+		t.Fatalf("got running totals %v", totals)
+	}
+	if len(seen) != count {
+		t.Fatalf("got %d distinct addresses, want %d", len(seen), count)
+	}
+	if got := countKeys(t, i); got != int64(count) {
+		t.Fatalf("got %d stored keys, want %d", got, count)
+	}
+}
+
+// TestIssueAddressesEmitFailure stops the batch when a chunk cannot be
+// reported; the chunks stored so far are kept.
+func TestIssueAddressesEmitFailure(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{})
+	errGone := errors.New("client gone")
+	chunks := 0
+	err := i.IssueAddresses(context.Background(), testBatchRequest, 3*BatchChunkSize, func([]Address, int) error {
+		chunks++
+		if chunks == 2 {
+			return errGone
+		}
+		return nil
+	})
+	if !errors.Is(err, errGone) {
+		t.Fatalf("got %v, want the emit error", err)
+	}
+	if chunks != 2 {
+		t.Fatalf("emitted %d chunks after the failure", chunks)
+	}
+	if got := countKeys(t, i); got != 2*BatchChunkSize {
+		t.Fatalf("got %d stored keys, want %d", got, 2*BatchChunkSize)
+	}
+}
+
+func TestIssueAddressesCancel(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks := 0
+	err := i.IssueAddresses(ctx, testBatchRequest, 3*BatchChunkSize, func([]Address, int) error {
+		chunks++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if chunks != 1 {
+		t.Fatalf("emitted %d chunks after cancellation", chunks)
+	}
+	if got := countKeys(t, i); got != BatchChunkSize {
+		t.Fatalf("got %d stored keys, want %d", got, BatchChunkSize)
+	}
+
+	err = i.IssueAddresses(ctx, testBatchRequest, 1, func([]Address, int) error {
+		t.Fatal("cancelled batch emitted a chunk")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestIssueAddressesInvalid(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{})
+	emit := func([]Address, int) error { return nil }
+	for _, count := range []int{0, -1, MaxBatchCount + 1} {
+		if err := i.IssueAddresses(context.Background(), testBatchRequest, count, emit); !errors.Is(err, ErrInvalidCount) {
+			t.Errorf("count %d: got %v, want ErrInvalidCount", count, err)
+		}
+	}
+	err := i.IssueAddresses(context.Background(), &AddressRequest{Chain: chains.Ethereum, Network: chains.MainNet}, 1, emit)
+	if !errors.Is(err, ErrBusinessIdRequired) {
+		t.Errorf("got %v, want ErrBusinessIdRequired", err)
+	}
+	if got := countKeys(t, i); got != 0 {
+		t.Fatalf("invalid batches stored %d keys", got)
+	}
+}
@@ -0,0 +1,441 @@
+package issuer
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/addresses"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/hdwallet"
+)
+
+const (
+	ChainBitcoin  = chains.Bitcoin
+	ChainEthereum = chains.Ethereum
+)
+
+var (
+	ErrBusinessIdRequired     = errors.New("business_id is required")
+	ErrUnsupportedChain       = chains.ErrUnsupportedChain
+	ErrUnsupportedNetwork     = chains.ErrUnsupportedNetwork
+	ErrUnsupportedAddressType = errors.New("unsupported address type")
+	ErrDerivationExhausted    = errors.New("no derivation index left")
+)
+
+type Address struct {
+	PublicKey   string `json:"public_key"`
+	Address     string `json:"address"`
+	AddressType string `json:"address_type"`
+	// DerivationPath and DerivationIndex are only set for HD addresses.
+	DerivationPath  string  `json:"derivation_path,omitempty"`
+	DerivationIndex *uint32 `json:"derivation_index,omitempty"`
+}
+
+// AddressRequest describes the address to issue.
+type AddressRequest struct {
+	BusinessId string
+	Chain      string
+	Network    string
+	// AddressType is the Bitcoin address type, empty for the default type. It
+	// must be empty for Ethereum.
+	AddressType string
+	// HD derives the key from the business seed at the next unused index
+	// instead of generating a random key.
+	HD bool
+}
+
+// keyPair is a freshly generated key with the address derived for one chain.
+type keyPair struct {
+	PrivateKey  string
+	PublicKey   string
+	Address     string
+	AddressType string
+}
+
+// Issuer generates new key pairs and persists them for a business id.
+//
+// It is shared by the rest and rpc services so that both transports hand out
+// the same kind of address for the same chain and network. Private keys are
+// sealed with the envelope cipher before they reach the database.
+//
+// Only the chains, networks and Bitcoin address formats of its chain registry
+// are issued.
+//
+// With a non-zero pool size, default addresses are assigned from a pool of
+// pre-generated keys that is refilled in the background between Start and
+// Stop.
+type Issuer struct {
+	db     *database.DB
+	cipher *envelope.Cipher
+	chains *chains.Registry
+	pool   *pool
+}
+
+// NewIssuer creates an issuer for the chains of the registry, or of the
+// default registry when it is nil. The pool, if enabled, holds keys for every
+// network of the registry.
+func NewIssuer(db *database.DB, cipher *envelope.Cipher, registry *chains.Registry, poolCfg PoolConfig) *Issuer {
+	if registry == nil {
+		registry = chains.DefaultRegistry()
+	}
+	i := &Issuer{db: db, cipher: cipher, chains: registry}
+	if poolCfg.Size > 0 {
+		i.pool = newPool(i, poolCfg, PoolTargets(registry))
+	}
+	return i
+}
+
+// Start launches the address pool refill workers, if the pool is enabled.
+func (i *Issuer) Start() {
+	if i.pool != nil {
+		i.pool.start()
+	}
+}
+
+// Stop stops the address pool refill workers and waits for them to exit.
+func (i *Issuer) Stop() {
+	if i.pool != nil {
+		i.pool.stop()
+	}
+}
+
+// IssueAddress creates a key pair for the requested chain and network, stores
+// it for the business id and returns the derived address and public key.
+//
+// The key is written inside a transaction before the address is returned, so
+// an address is never handed out unless its private key has been stored.
+// Requests for the default address type are served from the address pool
+// when it is enabled and not empty.
+//
+// Parameters:
+//   - req: A pointer to an AddressRequest object describing the business,
+//     chain, network and address type, and whether the key is derived from
+//     the business seed.
+//
+// Returns:
+//   - A pointer to an Address object containing the address and public key,
+//     and the derivation path and index for HD addresses.
+//   - ErrBusinessIdRequired, ErrUnsupportedChain, ErrUnsupportedNetwork or
+//     ErrUnsupportedAddressType for invalid requests, or the storage error if
+//     the key could not be persisted.
+func (i *Issuer) IssueAddress(req *AddressRequest) (*Address, error) {
+	if req.BusinessId == "" {
+		return nil, ErrBusinessIdRequired
+	}
+	addressType, err := i.normalizeAddressType(req.Chain, req.Network, req.AddressType)
+	if err != nil {
+		return nil, err
+	}
+	if req.HD {
+		return i.issueHDAddress(req.BusinessId, req.Chain, req.Network, addressType)
+	}
+	if i.pool != nil {
+		address, err := i.pool.assign(req.BusinessId, req.Chain, req.Network, addressType)
+		if err != nil {
+			return nil, err
+		}
+		if address != nil {
+			return address, nil
+		}
+	}
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	pair, err := i.newKeyPair(prvKey, req.Chain, req.Network, addressType)
+	if err != nil {
+		return nil, err
+	}
+	key, err := i.sealKey(req.BusinessId, req.Chain, req.Network, pair)
+	if err != nil {
+		return nil, err
+	}
+	err = i.db.Transaction(func(tx *database.DB) error {
+		return tx.Keys.StoreKeys([]database.Keys{*key}, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordAddresses(req.Chain, req.Network, "request", 1)
+	return &Address{
+		PublicKey:   pair.PublicKey,
+		Address:     pair.Address,
+		AddressType: pair.AddressType,
+	}, nil
+}
+
+// issueHDAddress derives the key at the next unused index of the business
+// seed, creating the seed on first use.
+//
+// Indexes are counted per derivation path, across the chains and networks
+// that derive under it. The seed row stays locked until the key has been
+// stored, so concurrent requests for the same business never derive the same
+// index.
+func (i *Issuer) issueHDAddress(businessId, chain, network, addressType string) (*Address, error) {
+	basePath, err := i.derivationBasePath(chain, network, addressType)
+	if err != nil {
+		return nil, err
+	}
+	var address *Address
+	err = i.db.Transaction(func(tx *database.DB) error {
+		mnemonic, err := i.lockSeed(tx, businessId)
+		if err != nil {
+			return err
+		}
+		maxIndex, err := tx.Keys.QueryMaxDerivationIndex(businessId, basePath)
+		if err != nil {
+			return err
+		}
+		var index uint32
+		if maxIndex != nil {
+			if *maxIndex >= hdkeychain.HardenedKeyStart-1 {
+				return ErrDerivationExhausted
+			}
+			index = *maxIndex + 1
+		}
+		path := fmt.Sprintf("%s/%d", basePath, index)
+		prvKey, err := hdwallet.DeriveKey(mnemonic, path)
+		if err != nil {
+			return err
+		}
+		pair, err := i.newKeyPair(prvKey, chain, network, addressType)
+		if err != nil {
+			return err
+		}
+		key, err := i.sealKey(businessId, chain, network, pair)
+		if err != nil {
+			return err
+		}
+		key.DerivationPath = path
+		key.DerivationIndex = &index
+		if err := tx.Keys.StoreKeys([]database.Keys{*key}, 1); err != nil {
+			return err
+		}
+		address = &Address{
+			PublicKey:       pair.PublicKey,
+			Address:         pair.Address,
+			AddressType:     pair.AddressType,
+			DerivationPath:  path,
+			DerivationIndex: &index,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordAddresses(chain, network, "hd", 1)
+	return address, nil
+}
+
+// lockSeed locks the seed row of a business inside tx and returns its
+// mnemonic, generating and storing a new seed if the business has none.
+func (i *Issuer) lockSeed(tx *database.DB, businessId string) (string, error) {
+	seed, err := tx.Seeds.LockSeedByBusId(businessId)
+	if err != nil {
+		return "", err
+	}
+	if seed == nil {
+		mnemonic, err := hdwallet.NewMnemonic()
+		if err != nil {
+			return "", err
+		}
+		if err := i.storeSeed(tx, businessId, mnemonic); err != nil {
+			return "", err
+		}
+		// Lock again: a concurrent request may have stored its seed first.
+		if seed, err = tx.Seeds.LockSeedByBusId(businessId); err != nil {
+			return "", err
+		}
+		if seed == nil {
+			return "", errors.New("seed not stored")
+		}
+	}
+	return i.openSeed(seed)
+}
+
+// storeSeed seals a mnemonic and stores it as the seed of a business.
+func (i *Issuer) storeSeed(tx *database.DB, businessId, mnemonic string) error {
+	sealed, err := i.cipher.Seal([]byte(mnemonic), []byte(businessId))
+	if err != nil {
+		return err
+	}
+	return tx.Seeds.StoreSeed(&database.Seeds{
+		BusinessId: businessId,
+		Mnemonic:   hex.EncodeToString(sealed.Ciphertext),
+		DataKey:    hex.EncodeToString(sealed.DataKey),
+		KeyVersion: sealed.KeyVersion,
+		Timestamp:  uint64(time.Now().Unix()),
+	})
+}
+
+// openSeed decrypts the mnemonic of a stored seed.
+func (i *Issuer) openSeed(seed *database.Seeds) (string, error) {
+	ciphertext, err := hex.DecodeString(seed.Mnemonic)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := hex.DecodeString(seed.DataKey)
+	if err != nil {
+		return "", err
+	}
+	mnemonic, err := i.cipher.Open(&envelope.Sealed{
+		Ciphertext: ciphertext,
+		DataKey:    dataKey,
+		KeyVersion: seed.KeyVersion,
+	}, []byte(seed.BusinessId))
+	if err != nil {
+		return "", err
+	}
+	return string(mnemonic), nil
+}
+
+// derivationBasePath returns the path up to the external chain for HD keys of
+// the chain, network and address type, as configured in the chain registry;
+// the address index is appended to it. The registry path of a Bitcoin
+// network is the one of the default address type: its purpose level is
+// replaced by the BIP-44, 49, 84 or 86 purpose of the requested type.
+func (i *Issuer) derivationBasePath(chain, network, addressType string) (string, error) {
+	n, err := i.chains.Network(chain, network)
+	if err != nil {
+		return "", err
+	}
+	path, err := accounts.ParseDerivationPath(n.DerivationPath)
+	if err != nil {
+		return "", err
+	}
+	if chain == ChainBitcoin {
+		purpose, ok := bitcoinPurposes[addressType]
+		if !ok {
+			return "", ErrUnsupportedAddressType
+		}
+		path[0] = hdkeychain.HardenedKeyStart + purpose
+	}
+	return path.String(), nil
+}
+
+var bitcoinPurposes = map[string]uint32{
+	addresses.AddressTypeP2PKH:      44,
+	addresses.AddressTypeP2SHP2WPKH: 49,
+	addresses.AddressTypeP2WPKH:     84,
+	addresses.AddressTypeP2TR:       86,
+}
+
+// normalizeAddressType validates the chain, network and address type of a
+// request against the registry and returns the address type to store,
+// applying the Bitcoin default. Chain and network are canonical names.
+func (i *Issuer) normalizeAddressType(chain, network, addressType string) (string, error) {
+	c, err := i.chains.Chain(chain)
+	if err != nil || c.Name != chain {
+		return "", ErrUnsupportedChain
+	}
+	n, err := i.chains.Network(chain, network)
+	if err != nil || n.Name != network {
+		return "", ErrUnsupportedNetwork
+	}
+	switch chain {
+	case ChainEthereum:
+		if addressType != "" {
+			return "", ErrUnsupportedAddressType
+		}
+		return "", nil
+	case ChainBitcoin:
+		if addressType == "" {
+			addressType = addresses.DefaultBitcoinAddressType
+		}
+		if !addresses.IsBitcoinAddressType(addressType) || !slices.Contains(c.AddressFormats, addressType) {
+			return "", ErrUnsupportedAddressType
+		}
+		if _, err := addresses.BitcoinNetParams(n.Params); err != nil {
+			return "", ErrUnsupportedNetwork
+		}
+		return addressType, nil
+	default:
+		return "", ErrUnsupportedChain
+	}
+}
+
+// newKeyPair derives the address of prvKey for the chain. Ethereum keys carry
+// the uncompressed public key, Bitcoin keys the compressed one.
+func (i *Issuer) newKeyPair(prvKey *ecdsa.PrivateKey, chain, network, addressType string) (*keyPair, error) {
+	pair := &keyPair{
+		PrivateKey:  hex.EncodeToString(crypto.FromECDSA(prvKey)),
+		AddressType: addressType,
+	}
+	switch chain {
+	case ChainEthereum:
+		pair.PublicKey = hex.EncodeToString(crypto.FromECDSAPub(&prvKey.PublicKey))
+		pair.Address = crypto.PubkeyToAddress(prvKey.PublicKey).Hex()
+	case ChainBitcoin:
+		pair.PublicKey = hex.EncodeToString(crypto.CompressPubkey(&prvKey.PublicKey))
+		n, err := i.chains.Network(chain, network)
+		if err != nil {
+			return nil, err
+		}
+		address, err := addresses.PublicKeyToBitcoinAddress(pair.PublicKey, addressType, n.Params)
+		if err != nil {
+			return nil, err
+		}
+		pair.Address = address
+	default:
+		return nil, ErrUnsupportedChain
+	}
+	return pair, nil
+}
+
+// sealKey builds the keys row for a freshly generated key pair, encrypting the
+// private key under a new data key bound to the row guid.
+func (i *Issuer) sealKey(businessId, chain, network string, pair *keyPair) (*database.Keys, error) {
+	privateKey, err := hex.DecodeString(pair.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	guid := uuid.New()
+	sealed, err := i.cipher.Seal(privateKey, guid[:])
+	if err != nil {
+		return nil, err
+	}
+	return &database.Keys{
+		GUID:        guid,
+		BusinessId:  businessId,
+		PrivateKey:  hex.EncodeToString(sealed.Ciphertext),
+		DataKey:     hex.EncodeToString(sealed.DataKey),
+		KeyVersion:  sealed.KeyVersion,
+		PublicKey:   pair.PublicKey,
+		Address:     pair.Address,
+		Chain:       chain,
+		Network:     network,
+		AddressType: pair.AddressType,
+		Timestamp:   uint64(time.Now().Unix()),
+	}, nil
+}
+
+// KeyAddress returns the address a key was issued with. Rows written before
+// the address was stored fall back to the Ethereum address of the public key.
+func KeyAddress(k database.Keys) (string, error) {
+	if k.Address != "" {
+		return k.Address, nil
+	}
+	return addresses.PublicKeyToAddress(k.PublicKey)
+}
+
+// IsInvalidRequest reports whether err was caused by the caller's input
+// rather than by key generation or storage.
+func IsInvalidRequest(err error) bool {
+	return errors.Is(err, ErrBusinessIdRequired) ||
+		errors.Is(err, ErrUnsupportedChain) ||
+		errors.Is(err, ErrUnsupportedNetwork) ||
+		errors.Is(err, ErrUnsupportedAddressType) ||
+		errors.Is(err, ErrInvalidCount)
+}
@@ -0,0 +1,215 @@
+package issuer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/addresses"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+// newTestIssuer returns an issuer for the chains of registry backed by an
+// in-memory sqlite database.
+func newTestIssuer(t *testing.T, registry *chains.Registry, poolCfg PoolConfig) *Issuer {
+	t.Helper()
+	db, err := database.NewDB(context.Background(), config.DBConfig{Driver: database.DriverSQLite, Path: database.SQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.PrepareSchema(""); err != nil {
+		t.Fatal(err)
+	}
+	keyCipher, err := envelope.NewCipher(make([]byte, 32), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := NewIssuer(db, keyCipher, registry, poolCfg)
+	t.Cleanup(i.Stop)
+	return i
+}
+
+// TestRegistryDerivationPaths checks that keys of the default address type
+// are derived at the path the registry advertises for their network.
+func TestRegistryDerivationPaths(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{})
+	for _, c := range chains.DefaultRegistry().Chains() {
+		for _, n := range c.Networks {
+			addressType, err := i.normalizeAddressType(c.Name, n.Name, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			path, err := i.derivationBasePath(c.Name, n.Name, addressType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != n.DerivationPath {
+				t.Errorf("%s/%s: registry path %s, derived at %s", c.Name, n.Name, n.DerivationPath, path)
+			}
+		}
+	}
+	path, err := i.derivationBasePath(chains.Bitcoin, chains.TestNet, addresses.AddressTypeP2TR)
+	if err != nil || path != "m/86'/1'/0'/0" {
+		t.Fatalf("got p2tr path %s, %v", path, err)
+	}
+}
+
+// TestConfiguredDerivation issues HD addresses on networks and at paths that
+// only exist in the config.
+func TestConfiguredDerivation(t *testing.T) {
+	registry, err := chains.FromConfig([]config.ChainConfig{
+		{Name: chains.Bitcoin, Networks: []config.NetworkConfig{{Name: "Regtest", Params: "regtest", DerivationPath: "m/84'/1'/7'/0"}}},
+		{Name: chains.Ethereum, DerivationPath: "m/44'/60'/3'/0", Networks: []config.NetworkConfig{{Name: "Holesky", ChainId: 17000}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := newTestIssuer(t, registry, PoolConfig{})
+	for _, tt := range []struct {
+		chain, network, addressType, path, prefix string
+	}{
+		{chains.Bitcoin, "Regtest", "", "m/84'/1'/7'/0/0", "bcrt1q"},
+		{chains.Bitcoin, "Regtest", addresses.AddressTypeP2TR, "m/86'/1'/7'/0/0", "bcrt1p"},
+		{chains.Ethereum, "Holesky", "", "m/44'/60'/3'/0/0", "0x"},
+	} {
+		address, err := i.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: tt.chain, Network: tt.network, AddressType: tt.addressType, HD: true})
+		if err != nil {
+			t.Fatalf("%s/%s: %v", tt.chain, tt.network, err)
+		}
+		if address.DerivationPath != tt.path || !strings.HasPrefix(address.Address, tt.prefix) {
+			t.Errorf("%s/%s: got %s at %s", tt.chain, tt.network, address.Address, address.DerivationPath)
+		}
+	}
+}
+
+func TestConfiguredChains(t *testing.T) {
+	registry, err := chains.FromConfig([]config.ChainConfig{
+		{Name: chains.Bitcoin, AddressFormats: []string{"p2wpkh", "p2pkh"}, Networks: []config.NetworkConfig{{Name: chains.TestNet}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := newTestIssuer(t, registry, PoolConfig{})
+	for _, tt := range []struct {
+		chain, network, addressType string
+		err                         error
+	}{
+		{chains.Bitcoin, chains.TestNet, "", nil},
+		{chains.Bitcoin, chains.TestNet, "p2pkh", nil},
+		{chains.Bitcoin, chains.TestNet, "p2tr", ErrUnsupportedAddressType},
+		{chains.Bitcoin, chains.MainNet, "", ErrUnsupportedNetwork},
+		{chains.Ethereum, chains.MainNet, "", ErrUnsupportedChain},
+	} {
+		_, err := i.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: tt.chain, Network: tt.network, AddressType: tt.addressType})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s/%s/%s: got %v, want %v", tt.chain, tt.network, tt.addressType, err, tt.err)
+		}
+	}
+	if got := PoolTargets(registry); len(got) != 1 || got[0] != (PoolTarget{Chain: chains.Bitcoin, Network: chains.TestNet}) {
+		t.Errorf("got pool targets %v", got)
+	}
+}
+
+func issueHD(t *testing.T, i *Issuer, businessId string) uint32 {
+	t.Helper()
+	address, err := i.IssueAddress(&AddressRequest{BusinessId: businessId, Chain: chains.Bitcoin, Network: chains.TestNet, HD: true})
+	if err != nil {
+		t.Error(err)
+		return 0
+	}
+	if address.DerivationIndex == nil {
+		t.Error("HD address has no derivation index")
+		return 0
+	}
+	return *address.DerivationIndex
+}
+
+func TestHDIndexSequential(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{})
+	for want := uint32(0); want < 5; want++ {
+		if got := issueHD(t, i, "merchant-1"); got != want {
+			t.Fatalf("got index %d, want %d", got, want)
+		}
+	}
+	// Every business derives from its own seed, starting at 0.
+	if got := issueHD(t, i, "merchant-2"); got != 0 {
+		t.Fatalf("got index %d for another business, want 0", got)
+	}
+}
+
+// TestHDIndexSharedPath derives keys on two networks that share a derivation
+// path; they draw from one counter, so the same key is never issued twice.
+func TestHDIndexSharedPath(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{})
+	seen := make(map[string]bool)
+	for want, network := range []string{chains.MainNet, chains.TestNet, chains.MainNet} {
+		address, err := i.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: chains.Ethereum, Network: network, HD: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *address.DerivationIndex != uint32(want) {
+			t.Fatalf("%s: got index %d, want %d", network, *address.DerivationIndex, want)
+		}
+		if seen[address.Address] {
+			t.Fatalf("%s: address %s issued twice", network, address.Address)
+		}
+		seen[address.Address] = true
+	}
+}
+
+func TestHDIndexConcurrent(t *testing.T) {
+	const count = 20
+	i := newTestIssuer(t, nil, PoolConfig{})
+	indexes := make(chan uint32, count)
+	var wg sync.WaitGroup
+	for n := 0; n < count; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			indexes <- issueHD(t, i, "merchant-1")
+		}()
+	}
+	wg.Wait()
+	close(indexes)
+	seen := make(map[uint32]bool)
+	for index := range indexes {
+		if seen[index] {
+			t.Errorf("index %d derived twice", index)
+		}
+		seen[index] = true
+	}
+	for index := uint32(0); index < count; index++ {
+		if !seen[index] {
+			t.Errorf("index %d skipped", index)
+		}
+	}
+}
+
+// TestHDIndexUnique checks that the schema rejects a second key at a
+// derivation index that is already taken.
+func TestHDIndexUnique(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{})
+	address, err := i.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: chains.Bitcoin, Network: chains.TestNet, HD: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := i.db.Keys.QueryKeyByAddress(address.Address)
+	if err != nil || key == nil {
+		t.Fatalf("issued key not stored: %v", err)
+	}
+	duplicate := *key
+	duplicate.GUID = uuid.New()
+	duplicate.Address = "duplicate"
+	duplicate.PublicKey = "duplicate"
+	if err := i.db.Keys.StoreKeys([]database.Keys{duplicate}, 1); err == nil {
+		t.Fatal("stored a second key at the same derivation index")
+	}
+}
@@ -0,0 +1,211 @@
+package issuer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+const defaultRefillInterval = 30 * time.Second
+
+// PoolConfig configures the pool of pre-generated keys.
+type PoolConfig struct {
+	// Size is the number of unassigned keys a refill tops each pool up to.
+	// Zero disables the pool.
+	Size int
+	// LowWater is the number of unassigned keys under which a pool is refilled.
+	LowWater int
+	// RefillInterval is how often the pools are checked when no address is
+	// being assigned.
+	RefillInterval time.Duration
+}
+
+// PoolTarget is a chain and network for which keys are pre-generated.
+type PoolTarget struct {
+	Chain   string
+	Network string
+}
+
+// PoolTargets lists the chains and networks of the registry, all of which are
+// served from the pool. Bitcoin pools hold keys of the default address type
+// only; other address types and HD addresses are always generated on request.
+func PoolTargets(registry *chains.Registry) []PoolTarget {
+	var targets []PoolTarget
+	for _, c := range registry.Chains() {
+		for _, n := range c.Networks {
+			targets = append(targets, PoolTarget{Chain: c.Name, Network: n.Name})
+		}
+	}
+	return targets
+}
+
+// poolKey identifies one pool. The address type is the default of the chain.
+type poolKey struct {
+	chain       string
+	network     string
+	addressType string
+}
+
+// pool keeps a number of unassigned keys in the keys table for every target,
+// so issuing an address is a single update instead of key generation and an
+// insert.
+//
+// Every pool has its own worker which refills it on a timer and whenever an
+// assignment notices it may be running low.
+type pool struct {
+	issuer *Issuer
+	cfg    PoolConfig
+	refill map[poolKey]chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newPool(issuer *Issuer, cfg PoolConfig, targets []PoolTarget) *pool {
+	if cfg.LowWater > cfg.Size {
+		cfg.LowWater = cfg.Size
+	}
+	if cfg.RefillInterval <= 0 {
+		cfg.RefillInterval = defaultRefillInterval
+	}
+	p := &pool{
+		issuer: issuer,
+		cfg:    cfg,
+		refill: make(map[poolKey]chan struct{}, len(targets)),
+	}
+	for _, target := range targets {
+		addressType, err := issuer.normalizeAddressType(target.Chain, target.Network, "")
+		if err != nil {
+			log.Warn("skip unsupported address pool", "chain", target.Chain, "network", target.Network, "err", err)
+			continue
+		}
+		key := poolKey{chain: target.Chain, network: target.Network, addressType: addressType}
+		p.refill[key] = make(chan struct{}, 1)
+	}
+	return p
+}
+
+// start launches one refill worker per pool.
+func (p *pool) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	for key, refill := range p.refill {
+		p.wg.Add(1)
+		go p.run(ctx, key, refill)
+	}
+}
+
+// stop cancels the refill workers and waits for them to exit.
+func (p *pool) stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+// assign hands one pooled key to the business id and wakes the refill worker
+// of the pool. It returns nil if there is no pool for the request or the pool
+// is empty, in which case the caller generates the key itself.
+func (p *pool) assign(businessId, chain, network, addressType string) (*Address, error) {
+	key := poolKey{chain: chain, network: network, addressType: addressType}
+	refill, ok := p.refill[key]
+	if !ok {
+		return nil, nil
+	}
+	defer func() {
+		select {
+		case refill <- struct{}{}:
+		default:
+		}
+	}()
+	pooled, err := p.issuer.db.Keys.AssignPooledKey(businessId, chain, network, addressType, uint64(time.Now().Unix()))
+	if err != nil {
+		return nil, err
+	}
+	if pooled == nil {
+		log.Warn("address pool is empty", "chain", chain, "network", network)
+		return nil, nil
+	}
+	return &Address{
+		PublicKey:   pooled.PublicKey,
+		Address:     pooled.Address,
+		AddressType: pooled.AddressType,
+	}, nil
+}
+
+func (p *pool) run(ctx context.Context, key poolKey, refill <-chan struct{}) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.RefillInterval)
+	defer ticker.Stop()
+	for {
+		if err := p.fill(ctx, key); err != nil {
+			log.Error("failed to refill address pool", "chain", key.chain, "network", key.network, "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-refill:
+		}
+	}
+}
+
+// fill tops the pool up to its size once it has dropped under the low-water
+// mark. Keys are generated and stored in batches of database.CreateBatchSize.
+//
+// Several processes may refill the same pool at once, which can leave it
+// above its size until enough keys have been assigned.
+func (p *pool) fill(ctx context.Context, key poolKey) error {
+	count, err := p.issuer.db.Keys.CountPooledKeys(key.chain, key.network, key.addressType)
+	if err != nil {
+		return err
+	}
+	if count >= int64(p.cfg.LowWater) && count > 0 {
+		return nil
+	}
+	missing := p.cfg.Size - int(count)
+	for missing > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil
+		}
+		keyList, err := p.generate(key, min(missing, database.CreateBatchSize))
+		if err != nil {
+			return err
+		}
+		if err := p.issuer.db.Keys.StoreKeys(keyList, database.CreateBatchSize); err != nil {
+			return err
+		}
+		missing -= len(keyList)
+		metrics.RecordAddresses(key.chain, key.network, "pool", len(keyList))
+	}
+	log.Info("refilled address pool", "chain", key.chain, "network", key.network, "size", p.cfg.Size)
+	return nil
+}
+
+func (p *pool) generate(key poolKey, n int) ([]database.Keys, error) {
+	keyList := make([]database.Keys, 0, n)
+	for len(keyList) < n {
+		prvKey, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		pair, err := p.issuer.newKeyPair(prvKey, key.chain, key.network, key.addressType)
+		if err != nil {
+			return nil, err
+		}
+		k, err := p.issuer.sealKey("", key.chain, key.network, pair)
+		if err != nil {
+			return nil, err
+		}
+		k.Pooled = true
+		keyList = append(keyList, *k)
+	}
+	return keyList, nil
+}
@@ -0,0 +1,155 @@
+package issuer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiaopengjun5162/go-rpc-service/services/addresses"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+var testPoolKey = poolKey{chain: chains.Bitcoin, network: chains.TestNet, addressType: addresses.DefaultBitcoinAddressType}
+
+func countPooled(t *testing.T, i *Issuer) int64 {
+	t.Helper()
+	count, err := i.db.Keys.CountPooledKeys(testPoolKey.chain, testPoolKey.network, testPoolKey.addressType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestPoolFill(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{Size: 5, LowWater: 2})
+	if err := i.pool.fill(context.Background(), testPoolKey); err != nil {
+		t.Fatal(err)
+	}
+	if got := countPooled(t, i); got != 5 {
+		t.Fatalf("got %d pooled keys, want 5", got)
+	}
+
+	// Above the low-water mark the pool is left alone.
+	for n := 0; n < 3; n++ {
+		if _, err := i.pool.assign("merchant-1", testPoolKey.chain, testPoolKey.network, testPoolKey.addressType); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := i.pool.fill(context.Background(), testPoolKey); err != nil {
+		t.Fatal(err)
+	}
+	if got := countPooled(t, i); got != 2 {
+		t.Fatalf("got %d pooled keys at the low-water mark, want 2", got)
+	}
+	if _, err := i.pool.assign("merchant-1", testPoolKey.chain, testPoolKey.network, testPoolKey.addressType); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.pool.fill(context.Background(), testPoolKey); err != nil {
+		t.Fatal(err)
+	}
+	if got := countPooled(t, i); got != 5 {
+		t.Fatalf("got %d pooled keys after refill, want 5", got)
+	}
+}
+
+// TestPoolAssign assigns pooled keys concurrently. sqlite serializes the
+// assignments that postgres spreads over rows with FOR UPDATE SKIP LOCKED;
+// either way no key may be handed out twice.
+func TestPoolAssign(t *testing.T) {
+	const size = 8
+	i := newTestIssuer(t, nil, PoolConfig{Size: size, LowWater: 1})
+	if err := i.pool.fill(context.Background(), testPoolKey); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+		wg   sync.WaitGroup
+	)
+	for n := 0; n < size; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			address, err := i.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: testPoolKey.chain, Network: testPoolKey.network})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[address.Address] {
+				t.Errorf("address %s assigned twice", address.Address)
+			}
+			seen[address.Address] = true
+		}()
+	}
+	wg.Wait()
+	if got := countPooled(t, i); got != 0 {
+		t.Fatalf("got %d pooled keys left, want 0", got)
+	}
+
+	keys, total, err := i.db.Keys.QueryKeysByBusId("merchant-1", 1, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != size {
+		t.Fatalf("got %d keys for the business, want %d", total, size)
+	}
+	for _, key := range keys {
+		if key.Pooled || !seen[key.Address] {
+			t.Errorf("unexpected key %+v", key)
+		}
+	}
+}
+
+func TestPoolEmptyFallback(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{Size: 5, LowWater: 2})
+	address, err := i.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: testPoolKey.chain, Network: testPoolKey.network})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := i.db.Keys.QueryKeyByAddress(address.Address)
+	if err != nil || key == nil {
+		t.Fatalf("issued key not stored: %v", err)
+	}
+	if key.Pooled || key.BusinessId != "merchant-1" {
+		t.Fatalf("unexpected key %+v", key)
+	}
+	if got := countPooled(t, i); got != 0 {
+		t.Fatalf("got %d pooled keys without a refill worker, want 0", got)
+	}
+}
+
+func TestPoolStop(t *testing.T) {
+	i := newTestIssuer(t, nil, PoolConfig{Size: 3, LowWater: 1, RefillInterval: time.Hour})
+	i.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for countPooled(t, i) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("refill workers did not fill the pool")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		i.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refill workers did not stop")
+	}
+
+	// Assignments after Stop still work but no longer trigger a refill.
+	if _, err := i.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: testPoolKey.chain, Network: testPoolKey.network}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := countPooled(t, i); got != 2 {
+		t.Fatalf("got %d pooled keys after stop, want 2", got)
+	}
+}
@@ -0,0 +1,129 @@
+package issuer
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+)
+
+// ResealBatchSize is the number of rows read per query while resealing.
+const ResealBatchSize = 500
+
+// Reseal re-encrypts every key and seed that is not sealed under the current
+// master key: plaintext keys written before envelope encryption and rows
+// sealed under a retired master key, which the cipher must still hold. Each
+// row is updated only if its key version has not changed since it was read,
+// so Reseal can run while the service is serving.
+//
+// Parameters:
+//   - ctx: A context.Context that cancels the run between rows.
+//
+// Returns:
+//   - int: The number of keys resealed.
+//   - int: The number of seeds resealed.
+//   - error: The context error, or an error naming the row that could not be
+//     opened or updated.
+func (i *Issuer) Reseal(ctx context.Context) (int, int, error) {
+	version := i.cipher.Version()
+	keyCount := 0
+	for {
+		keyList, err := i.db.Keys.QueryKeysNotSealedWith(version, ResealBatchSize)
+		if err != nil {
+			return keyCount, 0, err
+		}
+		if len(keyList) == 0 {
+			break
+		}
+		updated := 0
+		for _, key := range keyList {
+			if err := ctx.Err(); err != nil {
+				return keyCount, 0, err
+			}
+			fromVersion := key.KeyVersion
+			key.PrivateKey, key.DataKey, key.KeyVersion, err = i.reseal(key.PrivateKey, key.DataKey, fromVersion, key.GUID[:])
+			if err != nil {
+				return keyCount, 0, fmt.Errorf("failed to reseal key %s: %w", key.GUID, err)
+			}
+			ok, err := i.db.Keys.UpdateSealedKey(&key, fromVersion)
+			if err != nil {
+				return keyCount, 0, fmt.Errorf("failed to store key %s: %w", key.GUID, err)
+			}
+			if ok {
+				updated++
+			}
+		}
+		if updated == 0 {
+			return keyCount, 0, fmt.Errorf("none of %d keys resealed, they changed while being resealed", len(keyList))
+		}
+		keyCount += updated
+		log.Info("resealed keys", "count", keyCount, "key_version", version)
+	}
+
+	seedCount := 0
+	for {
+		seedList, err := i.db.Seeds.QuerySeedsNotSealedWith(version, ResealBatchSize)
+		if err != nil {
+			return keyCount, seedCount, err
+		}
+		if len(seedList) == 0 {
+			break
+		}
+		updated := 0
+		for _, seed := range seedList {
+			if err := ctx.Err(); err != nil {
+				return keyCount, seedCount, err
+			}
+			fromVersion := seed.KeyVersion
+			seed.Mnemonic, seed.DataKey, seed.KeyVersion, err = i.reseal(seed.Mnemonic, seed.DataKey, fromVersion, []byte(seed.BusinessId))
+			if err != nil {
+				return keyCount, seedCount, fmt.Errorf("failed to reseal seed of %s: %w", seed.BusinessId, err)
+			}
+			ok, err := i.db.Seeds.UpdateSealedSeed(&seed, fromVersion)
+			if err != nil {
+				return keyCount, seedCount, fmt.Errorf("failed to store seed of %s: %w", seed.BusinessId, err)
+			}
+			if ok {
+				updated++
+			}
+		}
+		if updated == 0 {
+			return keyCount, seedCount, fmt.Errorf("none of %d seeds resealed, they changed while being resealed", len(seedList))
+		}
+		seedCount += updated
+		log.Info("resealed seeds", "count", seedCount, "key_version", version)
+	}
+	return keyCount, seedCount, nil
+}
+
+// reseal opens a hex encoded value sealed under keyVersion, or taken as
+// plaintext for PlaintextVersion, and seals it again under the current master
+// key. It returns the hex encoded ciphertext and data key and the new version.
+func (i *Issuer) reseal(value, dataKey string, keyVersion uint32, additionalData []byte) (string, string, uint32, error) {
+	payload, err := hex.DecodeString(value)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if keyVersion != envelope.PlaintextVersion {
+		wrappedKey, err := hex.DecodeString(dataKey)
+		if err != nil {
+			return "", "", 0, err
+		}
+		payload, err = i.cipher.Open(&envelope.Sealed{
+			Ciphertext: payload,
+			DataKey:    wrappedKey,
+			KeyVersion: keyVersion,
+		}, additionalData)
+		if err != nil {
+			return "", "", 0, err
+		}
+	}
+	sealed, err := i.cipher.Seal(payload, additionalData)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return hex.EncodeToString(sealed.Ciphertext), hex.EncodeToString(sealed.DataKey), sealed.KeyVersion, nil
+}
@@ -0,0 +1,99 @@
+package issuer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
+)
+
+// TestReseal rotates the master key from version 1 to 2 and reseals a random
+// key, an HD key with its seed and a plaintext key written before encryption.
+func TestReseal(t *testing.T) {
+	old := newTestIssuer(t, nil, PoolConfig{})
+	db := old.db
+	var issued []string
+	for _, hd := range []bool{false, true} {
+		address, err := old.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: chains.Ethereum, Network: chains.MainNet, HD: hd})
+		if err != nil {
+			t.Fatal(err)
+		}
+		issued = append(issued, address.Address)
+	}
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := database.Keys{
+		GUID:       uuid.New(),
+		BusinessId: "merchant-1",
+		PrivateKey: hex.EncodeToString(crypto.FromECDSA(prvKey)),
+		PublicKey:  hex.EncodeToString(crypto.FromECDSAPub(&prvKey.PublicKey)),
+		Address:    crypto.PubkeyToAddress(prvKey.PublicKey).Hex(),
+		Chain:      chains.Ethereum,
+		Network:    chains.MainNet,
+		Timestamp:  uint64(time.Now().Unix()),
+	}
+	if err := db.Keys.StoreKeys([]database.Keys{legacy}, 1); err != nil {
+		t.Fatal(err)
+	}
+	issued = append(issued, legacy.Address)
+
+	current, err := envelope.NewCipher(bytes.Repeat([]byte{0x02}, 32), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := envelope.NewCipher(bytes.Repeat([]byte{0x02}, 32), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.AddKey(make([]byte, 32), 1); err != nil {
+		t.Fatal(err)
+	}
+	keyCount, seedCount, err := NewIssuer(db, keyring, nil, PoolConfig{}).Reseal(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyCount != 3 || seedCount != 1 {
+		t.Fatalf("resealed %d keys and %d seeds, want 3 and 1", keyCount, seedCount)
+	}
+
+	// The retired key is no longer needed once every row is resealed.
+	s := signer.NewSigner(db.KeysView, db.Keys, current)
+	for _, address := range issued {
+		if _, err := s.SignMessage(&signer.MessageRequest{BusinessId: "merchant-1", Address: address, Message: "hello"}); err != nil {
+			t.Errorf("%s: %v", address, err)
+		}
+	}
+	if _, err := NewIssuer(db, current, nil, PoolConfig{}).IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: chains.Ethereum, Network: chains.MainNet, HD: true}); err != nil {
+		t.Fatalf("derive from resealed seed: %v", err)
+	}
+
+	keyCount, seedCount, err = NewIssuer(db, current, nil, PoolConfig{}).Reseal(context.Background())
+	if err != nil || keyCount != 0 || seedCount != 0 {
+		t.Fatalf("second run resealed %d keys and %d seeds: %v", keyCount, seedCount, err)
+	}
+}
+
+func TestResealUnknownVersion(t *testing.T) {
+	old := newTestIssuer(t, nil, PoolConfig{})
+	if _, err := old.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: chains.Ethereum, Network: chains.MainNet}); err != nil {
+		t.Fatal(err)
+	}
+	current, err := envelope.NewCipher(bytes.Repeat([]byte{0x02}, 32), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := NewIssuer(old.db, current, nil, PoolConfig{}).Reseal(context.Background()); err == nil {
+		t.Fatal("resealed without the retired master key")
+	}
+}
@@ -0,0 +1,84 @@
+package issuer
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/hdwallet"
+)
+
+var (
+	ErrSeedNotFound = errors.New("business has no seed")
+	ErrSeedExists   = errors.New("business already has another seed")
+)
+
+// ExportSeed returns the BIP-39 mnemonic HD keys of a business are derived
+// from.
+//
+// Together with the derivation paths of the chain registry, which every HD
+// key also records, the mnemonic recovers the business's HD keys in any
+// BIP-39 wallet, or in a new database through ImportSeed.
+//
+// Parameters:
+//   - businessId: The business whose seed is exported.
+//
+// Returns:
+//   - string: The mnemonic.
+//   - error: ErrSeedNotFound if the business never derived a key, or an error
+//     if the seed cannot be read or decrypted.
+func (i *Issuer) ExportSeed(businessId string) (string, error) {
+	if businessId == "" {
+		return "", ErrBusinessIdRequired
+	}
+	seed, err := i.db.Seeds.QuerySeedByBusId(businessId)
+	if err != nil {
+		return "", err
+	}
+	if seed == nil {
+		return "", ErrSeedNotFound
+	}
+	return i.openSeed(seed)
+}
+
+// ImportSeed restores an exported mnemonic as the seed of a business, sealed
+// with the current master key.
+//
+// HD addresses issued afterwards are derived from it, starting after the
+// highest index stored below each derivation path, so issuing them again on
+// an empty keys table yields the keys of the exported wallet in order.
+// Importing the seed a business already has is a no-op.
+//
+// Parameters:
+//   - businessId: The business the seed is restored for.
+//   - mnemonic: The BIP-39 mnemonic returned by ExportSeed.
+//
+// Returns:
+//   - error: hdwallet.ErrInvalidMnemonic, ErrSeedExists if the business has
+//     a different seed, or an error if the seed cannot be stored.
+func (i *Issuer) ImportSeed(businessId, mnemonic string) error {
+	if businessId == "" {
+		return ErrBusinessIdRequired
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !hdwallet.IsMnemonicValid(mnemonic) {
+		return hdwallet.ErrInvalidMnemonic
+	}
+	return i.db.Transaction(func(tx *database.DB) error {
+		seed, err := tx.Seeds.LockSeedByBusId(businessId)
+		if err != nil {
+			return err
+		}
+		if seed == nil {
+			return i.storeSeed(tx, businessId, mnemonic)
+		}
+		current, err := i.openSeed(seed)
+		if err != nil {
+			return err
+		}
+		if current != mnemonic {
+			return ErrSeedExists
+		}
+		return nil
+	})
+}
@@ -0,0 +1,58 @@
+package issuer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/hdwallet"
+)
+
+// TestSeedRecovery exports the seed of a business and imports it into an
+// empty database, which derives the same addresses again.
+func TestSeedRecovery(t *testing.T) {
+	old := newTestIssuer(t, nil, PoolConfig{})
+	if _, err := old.ExportSeed("merchant-1"); !errors.Is(err, ErrSeedNotFound) {
+		t.Fatalf("got %v, want ErrSeedNotFound", err)
+	}
+	var issued []string
+	for _, chain := range []string{chains.Bitcoin, chains.Ethereum} {
+		address, err := old.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: chain, Network: chains.MainNet, HD: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		issued = append(issued, address.Address)
+	}
+	mnemonic, err := old.ExportSeed("merchant-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newTestIssuer(t, nil, PoolConfig{})
+	if err := restored.ImportSeed("merchant-1", "not a mnemonic"); !errors.Is(err, hdwallet.ErrInvalidMnemonic) {
+		t.Fatalf("got %v, want ErrInvalidMnemonic", err)
+	}
+	if err := restored.ImportSeed("merchant-1", mnemonic); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.ImportSeed("merchant-1", mnemonic); err != nil {
+		t.Fatalf("importing the same seed again: %v", err)
+	}
+	for n, chain := range []string{chains.Bitcoin, chains.Ethereum} {
+		address, err := restored.IssueAddress(&AddressRequest{BusinessId: "merchant-1", Chain: chain, Network: chains.MainNet, HD: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address.Address != issued[n] {
+			t.Errorf("%s: restored %s, want %s", chain, address.Address, issued[n])
+		}
+	}
+
+	other, err := hdwallet.NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.ImportSeed("merchant-1", other); !errors.Is(err, ErrSeedExists) {
+		t.Fatalf("got %v, want ErrSeedExists", err)
+	}
+}
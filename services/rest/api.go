@@ -13,17 +13,29 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
 	"github.com/qiaopengjun5162/go-rpc-service/common/httputil"
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
 	"github.com/qiaopengjun5162/go-rpc-service/config"
 	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
 	"github.com/qiaopengjun5162/go-rpc-service/services/rest/routes"
 	"github.com/qiaopengjun5162/go-rpc-service/services/rest/service"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
 )
 
 const (
-	HealthPath          = "/health"
-	SupportChainV1Path  = "/api/v1/support_chain"
-	WalletAddressV1Path = "/api/v1/wallet_address"
+	HealthPath            = "/health"
+	SupportChainV1Path    = "/api/v1/support_chain"
+	WalletAddressV1Path   = "/api/v1/wallet_address"
+	KeysV1Path            = "/api/v1/keys"
+	SignTxV1Path          = "/api/v1/sign_transaction"
+	SignMessageV1Path     = "/api/v1/sign_message"
+	SignTypedDataV1Path   = "/api/v1/sign_typed_data"
+	SignPsbtV1Path        = "/api/v1/sign_psbt"
+	SupportedChainsV1Path = "/api/v1/supported_chains"
 )
 
 type APIConfig struct {
@@ -32,10 +44,14 @@ type APIConfig struct {
 }
 
 type API struct {
-	router    *chi.Mux
-	apiServer *httputil.HTTPServer
-	db        *database.DB
-	stopped   atomic.Bool
+	router        *chi.Mux
+	apiServer     *httputil.HTTPServer
+	metricsServer *httputil.HTTPServer
+	db            *database.DB
+	cipher        *envelope.Cipher
+	chains        *chains.Registry
+	issuer        *issuer.Issuer
+	stopped       atomic.Bool
 }
 
 // NewApi initializes a new API instance from the given configuration.
@@ -61,16 +77,17 @@ func NewApi(ctx context.Context, cfg *config.Config) (*API, error) {
 
 // initFromConfig initializes the API instance from the given configuration.
 //
-// It first calls `initDB` to initialize the database connection from the given
+// It first builds the chain registry from the configured chains and loads the
+// master key used to encrypt private keys at rest, then calls `initDB` to initialize the database connection from the given
 // configuration. If the initialization fails, it returns the error joined with
 // the stop error.
 //
 // Then it calls `initRouter` to initialize the API router from the given
 // configuration.
 //
-// Finally, it calls `startServer` to start the API server from the given
-// configuration. If the start fails, it returns the error joined with the stop
-// error.
+// Finally, it calls `startServer` and `startMetricsServer` to start the API
+// server and the metrics server from the given configuration. If a start fails,
+// it returns the error joined with the stop error.
 //
 // Parameters:
 //   - ctx: A context.Context that controls the initialization timeout.
@@ -79,6 +96,16 @@ func NewApi(ctx context.Context, cfg *config.Config) (*API, error) {
 // Returns:
 //   - error: An error if the initialization fails, or nil if successful.
 func (a *API) initFromConfig(ctx context.Context, cfg *config.Config) error {
+	registry, err := chains.FromConfig(cfg.Chains)
+	if err != nil {
+		return fmt.Errorf("invalid chain config: %w", err)
+	}
+	a.chains = registry
+	keyCipher, err := envelope.LoadKeyring(cfg.MasterKey.Key, cfg.MasterKey.File, cfg.MasterKey.Version, cfg.MasterKey.RetiredFile)
+	if err != nil {
+		return fmt.Errorf("failed to load master key: %w", err)
+	}
+	a.cipher = keyCipher
 	if err := a.initDB(ctx, cfg); err != nil {
 		return fmt.Errorf("failed to init DB: %w", err)
 	}
@@ -86,41 +113,63 @@ func (a *API) initFromConfig(ctx context.Context, cfg *config.Config) error {
 	if err := a.startServer(cfg.HTTPServer); err != nil {
 		return fmt.Errorf("failed to start API server: %w", err)
 	}
+	if err := a.startMetricsServer(cfg.MetricsServer); err != nil {
+		return fmt.Errorf("failed to start metrics server: %w", err)
+	}
 	return nil
 }
 
 // initRouter initializes the API router with the specified server configuration.
 //
 // It creates a new instance of the Validator and HandleSrv to set up the service
-// layer. A new chi router is created and configured with middleware for timeout,
-// recovery, and heartbeat. It also sets up HTTP GET endpoints for support chain
-// and wallet address using the provided routes.
+// layer. A new chi router is created and configured with middleware for request
+// ids, access logging and metrics, timeout, recovery, heartbeat and API key
+// authentication, and every route requires the scope matching what it does. It
+// also sets up HTTP GET endpoints for support chain, supported chains, wallet
+// address and keys, and HTTP POST endpoints for signing, using the provided
+// routes.
 //
 // Parameters:
 //   - conf: The server configuration for initializing the router.
 //   - cfg: The application configuration used to set up the service.
 func (a *API) initRouter(conf config.ServerConfig, cfg *config.Config) {
-	v := new(service.Validator)
+	v := service.NewValidator(a.chains)
 
-	svc := service.NewHandleSrv(v, a.db.Keys)
+	a.issuer = issuer.NewIssuer(a.db, a.cipher, a.chains, issuer.PoolConfig{
+		Size:           cfg.AddressPool.Size,
+		LowWater:       cfg.AddressPool.LowWater,
+		RefillInterval: cfg.AddressPool.RefillInterval,
+	})
+	svc := service.NewHandleSrv(v, a.db.KeysView, a.issuer, signer.NewSigner(a.db.KeysView, a.db.Keys, a.cipher))
 	apiRouter := chi.NewRouter()
 	h := routes.NewRoutes(apiRouter, svc)
 
+	apiRouter.Use(middleware.RequestID)
+	apiRouter.Use(routes.AccessLog())
 	apiRouter.Use(middleware.Timeout(time.Second * 12))
 	apiRouter.Use(middleware.Recoverer)
 
 	apiRouter.Use(middleware.Heartbeat(HealthPath))
+	apiRouter.Use(routes.APIKeyAuth(auth.NewAuthenticator(a.db.Tokens)))
 
 	apiRouter.Get(fmt.Sprintf(SupportChainV1Path), h.GetSupportCoins)
-	apiRouter.Get(fmt.Sprintf(WalletAddressV1Path), h.GetWalletAddress)
+	apiRouter.Get(fmt.Sprintf(SupportedChainsV1Path), h.ListSupportedChains)
+	apiRouter.With(routes.RequireScope(auth.ScopeAddressCreate)).Get(fmt.Sprintf(WalletAddressV1Path), h.GetWalletAddress)
+	apiRouter.With(routes.RequireScope(auth.ScopeKeysRead)).Get(fmt.Sprintf(KeysV1Path), h.ListKeys)
+	apiRouter.With(routes.RequireScope(auth.ScopeSign)).Post(fmt.Sprintf(SignTxV1Path), h.SignTransaction)
+	apiRouter.With(routes.RequireScope(auth.ScopeSign)).Post(fmt.Sprintf(SignMessageV1Path), h.SignMessage)
+	apiRouter.With(routes.RequireScope(auth.ScopeSign)).Post(fmt.Sprintf(SignTypedDataV1Path), h.SignTypedData)
+	apiRouter.With(routes.RequireScope(auth.ScopeSign)).Post(fmt.Sprintf(SignPsbtV1Path), h.SignPsbt)
 
 	a.router = apiRouter
 }
 
 // initDB initializes the database connection from the given configuration.
 //
-// It creates a new instance of the DB from the given configuration. If the
-// initialization fails, it logs the error and returns it.
+// It creates a new instance of the DB from the given configuration and checks
+// that no applied migration has been modified since, or applies the migrations
+// of an in-memory sqlite database. If the initialization
+// fails, it logs the error and returns it.
 //
 // Parameters:
 //   - ctx: A context.Context that controls the initialization timeout.
@@ -135,10 +184,15 @@ func (a *API) initDB(ctx context.Context, cfg *config.Config) error {
 		return err
 	}
 	a.db = initDb
+	if err := a.db.PrepareSchema(cfg.Migrations); err != nil {
+		log.Error("failed to prepare the database schema", "err", err)
+		return err
+	}
 	return nil
 }
 
-// Start starts the API server.
+// Start starts refilling the address pool. The API server itself is already
+// serving once NewApi returns.
 //
 // Parameters:
 //   - ctx: A context.Context that controls the start timeout.
@@ -146,12 +200,14 @@ func (a *API) initDB(ctx context.Context, cfg *config.Config) error {
 // Returns:
 //   - error: An error if the start fails, or nil if successful.
 func (a *API) Start(ctx context.Context) error {
+	a.issuer.Start()
 	return nil
 }
 
 // Stop stops the API service.
 //
-// It stops the API server and closes the database connection.
+// It stops the API server, the metrics server and the address pool workers
+// and closes the database connection.
 // If any of the shutdown operations fail, it joins the errors together
 // and returns the resulting error.
 //
@@ -167,6 +223,14 @@ func (a *API) Stop(ctx context.Context) error {
 			result = errors.Join(result, fmt.Errorf("failed to stop API server: %w", err))
 		}
 	}
+	if a.metricsServer != nil {
+		if err := a.metricsServer.Stop(ctx); err != nil {
+			result = errors.Join(result, fmt.Errorf("failed to stop metrics server: %w", err))
+		}
+	}
+	if a.issuer != nil {
+		a.issuer.Stop()
+	}
 	if a.db != nil {
 		if err := a.db.Close(); err != nil {
 			result = errors.Join(result, fmt.Errorf("failed to close DB: %w", err))
@@ -200,6 +264,27 @@ func (a *API) startServer(serverConfig config.ServerConfig) error {
 	return nil
 }
 
+// startMetricsServer exports the database pool stats and serves the metrics
+// registry on the host and port of the given server configuration.
+//
+// Parameters:
+//   - serverConfig: The ServerConfig containing the host and port for the metrics server.
+//
+// Returns:
+//   - error: An error if the server fails to start, or nil if successful.
+func (a *API) startMetricsServer(serverConfig config.ServerConfig) error {
+	if err := a.db.RegisterMetrics(); err != nil {
+		return err
+	}
+	srv, err := metrics.StartServer(serverConfig.Host, serverConfig.Port)
+	if err != nil {
+		return err
+	}
+	log.Info("metrics server started", "addr", srv.Addr().String())
+	a.metricsServer = srv
+	return nil
+}
+
 // Stopped returns a boolean indicating whether the API service has been stopped.
 //
 // It safely loads the stopped atomic boolean value and returns its value.
@@ -1,15 +1,150 @@
 package models
 
+import "encoding/json"
+
+// ErrorResponse is the body of every rest api error response.
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestId string `json:"request_id,omitempty"`
+}
+
 type ChainRequest struct {
 	Chain   string `json:"chain"`
 	Network string `json:"network"`
 }
 
+type WalletAddressRequest struct {
+	Chain       string `json:"chain"`
+	Network     string `json:"network"`
+	BusinessId  string `json:"business_id"`
+	AddressType string `json:"address_type"`
+	HD          bool   `json:"hd"`
+}
+
 type SupportChainResponse struct {
 	Support bool `json:"support"`
 }
 
+type NetworkInfo struct {
+	Name           string   `json:"name"`
+	Aliases        []string `json:"aliases,omitempty"`
+	ChainId        uint64   `json:"chain_id,omitempty"`
+	Params         string   `json:"params,omitempty"`
+	DerivationPath string   `json:"derivation_path"`
+}
+
+type ChainInfo struct {
+	Name           string        `json:"name"`
+	Aliases        []string      `json:"aliases,omitempty"`
+	Curve          string        `json:"curve"`
+	AddressFormats []string      `json:"address_formats"`
+	DerivationPath string        `json:"derivation_path"`
+	Networks       []NetworkInfo `json:"networks"`
+}
+
+type SupportedChainsResponse struct {
+	Chains []ChainInfo `json:"chains"`
+}
+
 type WalletAddressResponse struct {
-	PublicKey string `json:"publicKey"`
-	Address   string `json:"address"`
+	PublicKey       string  `json:"publicKey"`
+	Address         string  `json:"address"`
+	AddressType     string  `json:"addressType,omitempty"`
+	DerivationPath  string  `json:"derivationPath,omitempty"`
+	DerivationIndex *uint32 `json:"derivationIndex,omitempty"`
+}
+
+type KeysRequest struct {
+	BusinessId string `json:"business_id"`
+	Page       uint64 `json:"page"`
+	PageSize   uint64 `json:"page_size"`
+}
+
+type KeyInfo struct {
+	Guid           string `json:"guid"`
+	BusinessId     string `json:"business_id"`
+	PublicKey      string `json:"public_key"`
+	Address        string `json:"address"`
+	Timestamp      uint64 `json:"timestamp"`
+	Chain          string `json:"chain"`
+	Network        string `json:"network"`
+	AddressType    string `json:"address_type"`
+	DerivationPath string `json:"derivation_path,omitempty"`
+}
+
+type KeysResponse struct {
+	Page     uint64    `json:"page"`
+	PageSize uint64    `json:"page_size"`
+	Total    int64     `json:"total"`
+	Keys     []KeyInfo `json:"keys"`
+}
+
+type AccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+type SignTransactionRequest struct {
+	// BusinessId is the business of the API key, never read from the body.
+	BusinessId           string        `json:"-"`
+	PublicKey            string        `json:"publicKey"`
+	Address              string        `json:"address"`
+	ChainId              string        `json:"chainId"`
+	Type                 uint8         `json:"type"`
+	Nonce                uint64        `json:"nonce"`
+	To                   string        `json:"to"`
+	Value                string        `json:"value"`
+	Gas                  uint64        `json:"gas"`
+	GasPrice             string        `json:"gasPrice"`
+	MaxPriorityFeePerGas string        `json:"maxPriorityFeePerGas"`
+	MaxFeePerGas         string        `json:"maxFeePerGas"`
+	Data                 string        `json:"data"`
+	AccessList           []AccessTuple `json:"accessList"`
+}
+
+type SignTransactionResponse struct {
+	SignedTx string `json:"signedTx"`
+	TxHash   string `json:"txHash"`
+}
+
+type SignMessageRequest struct {
+	// BusinessId is the business of the API key, never read from the body.
+	BusinessId string `json:"-"`
+	PublicKey  string `json:"publicKey"`
+	Address    string `json:"address"`
+	Message    string `json:"message"`
+}
+
+type SignTypedDataRequest struct {
+	// BusinessId is the business of the API key, never read from the body.
+	BusinessId string          `json:"-"`
+	PublicKey  string          `json:"publicKey"`
+	Address    string          `json:"address"`
+	Network    string          `json:"network"`
+	TypedData  json.RawMessage `json:"typedData"`
+}
+
+type SignatureResponse struct {
+	Signature string `json:"signature"`
+	Hash      string `json:"hash"`
+}
+
+type SignPsbtRequest struct {
+	// BusinessId is the business of the API key, never read from the body.
+	BusinessId string `json:"-"`
+	Network    string `json:"network"`
+	Psbt       string `json:"psbt"`
+}
+
+type PsbtInput struct {
+	Index   uint32 `json:"index"`
+	Address string `json:"address"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type SignPsbtResponse struct {
+	Psbt          string      `json:"psbt"`
+	SignedInputs  []PsbtInput `json:"signedInputs"`
+	SkippedInputs []PsbtInput `json:"skippedInputs"`
 }
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rest/service"
+)
+
+// APIKeyHeader is the request header carrying the API key. API keys are the
+// consumer tokens issued with the token command.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuth returns a middleware that resolves the API key of every request
+// to a business id and scopes and stores the credential in the request
+// context. Requests without a usable key are rejected with a 401, requests
+// for another business id than the key's with a 403.
+//
+// Parameters:
+//   - authenticator: The authenticator shared with the rpc consumer_token check.
+//
+// Returns:
+//   - A chi compatible middleware.
+func APIKeyAuth(authenticator *auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cred, err := authenticator.Authenticate(r.Header.Get(APIKeyHeader))
+			if err != nil {
+				if auth.IsUnauthenticated(err) {
+					writeError(w, r, service.NewUnauthorizedError(err.Error()))
+					return
+				}
+				writeError(w, r, err)
+				return
+			}
+			r = r.WithContext(auth.NewContext(r.Context(), cred))
+			setAccessLogBusinessId(r, cred.BusinessId)
+			if _, err := requestBusinessId(r); err != nil {
+				writeError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope returns a middleware that rejects requests whose API key was
+// not granted scope with a 403. It must run after APIKeyAuth.
+//
+// Parameters:
+//   - scope: The scope the route requires.
+//
+// Returns:
+//   - A chi compatible middleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cred, ok := auth.FromContext(r.Context())
+			if !ok {
+				writeError(w, r, service.NewUnauthorizedError(auth.ErrTokenRequired.Error()))
+				return
+			}
+			if !cred.HasScope(scope) {
+				writeError(w, r, service.NewForbiddenError("api key lacks scope "+scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestBusinessId returns the business id a key-touching handler acts on:
+// the business of the request's API key. A business_id query parameter is
+// only accepted if it is the key's. Handlers check this themselves rather
+// than relying on APIKeyAuth having seen the parameter.
+func requestBusinessId(r *http.Request) (string, error) {
+	cred, ok := auth.FromContext(r.Context())
+	if !ok {
+		return "", service.NewUnauthorizedError(auth.ErrTokenRequired.Error())
+	}
+	if businessId := r.URL.Query().Get("business_id"); businessId != "" && businessId != cred.BusinessId {
+		return "", service.NewForbiddenError("business_id does not match api key")
+	}
+	return cred.BusinessId, nil
+}
+
+// callerBusinessId returns the business id of the request's API key. Keys
+// are only ever used on behalf of this business.
+func callerBusinessId(r *http.Request) string {
+	if cred, ok := auth.FromContext(r.Context()); ok {
+		return cred.BusinessId
+	}
+	return ""
+}
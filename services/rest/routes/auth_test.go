@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	models "github.com/qiaopengjun5162/go-rpc-service/services/rest/model"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rest/service"
+)
+
+// memTokensView is an in-memory database.TokensView keyed by token hash.
+type memTokensView map[string]*database.Tokens
+
+func (m memTokensView) QueryTokenByHash(tokenHash string) (*database.Tokens, error) {
+	return m[tokenHash], nil
+}
+
+func (m memTokensView) QueryTokensByBusId(string) ([]database.Tokens, error) {
+	return nil, nil
+}
+
+func newTestRouter() *chi.Mux {
+	tokens := memTokensView{
+		auth.HashToken("reader"):  {BusinessId: "merchant-1", Scopes: auth.ScopeKeysRead},
+		auth.HashToken("revoked"): {BusinessId: "merchant-1", Scopes: auth.ScopeKeysRead, Revoked: true},
+	}
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(AccessLog())
+	r.Use(APIKeyAuth(auth.NewAuthenticator(tokens)))
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		businessId, err := requestBusinessId(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		_ = jsonResponse(w, businessId, http.StatusOK)
+	}
+	r.With(RequireScope(auth.ScopeKeysRead)).Get("/keys", ok)
+	r.With(RequireScope(auth.ScopeSign)).Post("/sign", ok)
+	return r
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	router := newTestRouter()
+	tests := []struct {
+		name   string
+		method string
+		target string
+		apiKey string
+		status int
+		code   string
+		body   string
+	}{
+		{"missing key", http.MethodGet, "/keys", "", http.StatusUnauthorized, service.CodeUnauthorized, ""},
+		{"unknown key", http.MethodGet, "/keys", "unknown", http.StatusUnauthorized, service.CodeUnauthorized, ""},
+		{"revoked key", http.MethodGet, "/keys", "revoked", http.StatusUnauthorized, service.CodeUnauthorized, ""},
+		{"missing scope", http.MethodPost, "/sign", "reader", http.StatusForbidden, service.CodeForbidden, ""},
+		{"other business", http.MethodGet, "/keys?business_id=merchant-2", "reader", http.StatusForbidden, service.CodeForbidden, ""},
+		{"default business", http.MethodGet, "/keys", "reader", http.StatusOK, "", `"merchant-1"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.apiKey != "" {
+			req.Header.Set(APIKeyHeader, tt.apiKey)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: got body %s, want %s", tt.name, rec.Body.String(), tt.body)
+		}
+		if tt.code != "" {
+			var resp models.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if resp.Code != tt.code || resp.Message == "" || resp.RequestId == "" {
+				t.Errorf("%s: unexpected error body %+v", tt.name, resp)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/go-chi/chi/v5/middleware"
+
+	models "github.com/qiaopengjun5162/go-rpc-service/services/rest/model"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rest/service"
+)
+
+// errorStatus maps the service error codes onto HTTP status codes.
+var errorStatus = map[string]int{
+	service.CodeValidation:   http.StatusBadRequest,
+	service.CodeNotFound:     http.StatusNotFound,
+	service.CodeUnauthorized: http.StatusUnauthorized,
+	service.CodeForbidden:    http.StatusForbidden,
+	service.CodeInternal:     http.StatusInternalServerError,
+}
+
+// writeError writes err as an ErrorResponse with the HTTP status of its code.
+// Errors that are not service errors are reported as internal errors, and the
+// cause of every internal error is logged instead of returned.
+//
+// Parameters:
+//   - w: The http.ResponseWriter to write the error response to.
+//   - r: The http.Request that failed, used for its request id.
+//   - err: The error to report.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	svcErr := service.AsError(err)
+	statusCode, ok := errorStatus[svcErr.Code]
+	if !ok {
+		statusCode = http.StatusInternalServerError
+	}
+	requestId := middleware.GetReqID(r.Context())
+	if statusCode == http.StatusInternalServerError {
+		log.Error("request failed", "method", r.Method, "path", r.URL.Path, "request_id", requestId, "err", err)
+	}
+	resp := models.ErrorResponse{
+		Code:      svcErr.Code,
+		Message:   svcErr.Message,
+		RequestId: requestId,
+	}
+	if err := jsonResponse(w, resp, statusCode); err != nil {
+		log.Error("error writing response", "err", err)
+	}
+}
@@ -1,17 +1,21 @@
 package routes
 
 import (
-	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/qiaopengjun5162/go-rpc-service/database"
 	models "github.com/qiaopengjun5162/go-rpc-service/services/rest/model"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rest/service"
 )
 
 // GetSupportCoins handles the HTTP request to check if a specific blockchain and network
 // are supported by the service. It extracts the 'chain' and 'network' parameters from the
 // query string, constructs a ChainRequest, and calls the service's GetSupportCoins method.
 // If the service supports the given chain and network, it responds with a JSON indicating support.
-// If an error occurs during processing or in writing the response, it logs the error.
+// Service errors are written as an error envelope with the matching status.
 //
 // Parameters:
 //   - w: The http.ResponseWriter used to write the JSON response.
@@ -25,38 +29,138 @@ func (h Routes) GetSupportCoins(w http.ResponseWriter, r *http.Request) {
 	}
 	supRet, err := h.svc.GetSupportCoins(cr)
 	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 	err = jsonResponse(w, supRet, http.StatusOK)
 	if err != nil {
-		fmt.Println("Error writing response", "err", err.Error())
+		log.Error("error writing response", "err", err)
 	}
 }
 
-// GetWalletAddress handles the HTTP request to retrieve a wallet address for a specific
-// blockchain and network. It extracts the 'chain' and 'network' parameters from the query string,
-// constructs a ChainRequest, and calls the service's GetWalletAddress method. The wallet address
-// and public key are returned in a JSON response. If an error occurs during processing or in writing
-// the response, it logs the error.
+// ListSupportedChains handles the HTTP request to list the chains supported by
+// the service, with their networks, curve, address formats and derivation path.
 //
 // Parameters:
 //   - w: The http.ResponseWriter used to write the JSON response.
-//   - r: The http.Request containing the chain and network query parameters.
+//   - r: The http.Request.
+func (h Routes) ListSupportedChains(w http.ResponseWriter, r *http.Request) {
+	chainList, err := h.svc.ListSupportedChains()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = jsonResponse(w, chainList, http.StatusOK)
+	if err != nil {
+		log.Error("error writing response", "err", err)
+	}
+}
+
+// GetWalletAddress handles the HTTP request to issue a wallet address for a specific
+// blockchain, network and business id. It extracts the 'chain', 'network', 'business_id' and the
+// optional 'address_type' and 'hd' parameters from the query string, constructs a WalletAddressRequest,
+// and calls the service's GetWalletAddress method. A missing 'business_id' defaults to the business
+// of the API key. The wallet address and public key are returned in a JSON response, together with
+// the derivation path and index for hd=true. A 'business_id' other than the API key's is rejected
+// with a 403, invalid requests with a 400 and failures to generate or store the key with a 500.
+//
+// Parameters:
+//   - w: The http.ResponseWriter used to write the JSON response.
+//   - r: The http.Request containing the chain, network and business id query parameters.
 func (h Routes) GetWalletAddress(w http.ResponseWriter, r *http.Request) {
-	chain := r.URL.Query().Get("chain")
-	network := r.URL.Query().Get("network")
-	cr := &models.ChainRequest{
-		Chain:   chain,
-		Network: network,
+	hd, err := parseBoolParam(r, "hd")
+	if err != nil {
+		writeError(w, r, service.NewValidationError("invalid hd"))
+		return
+	}
+	businessId, err := requestBusinessId(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	wr := &models.WalletAddressRequest{
+		Chain:       r.URL.Query().Get("chain"),
+		Network:     r.URL.Query().Get("network"),
+		BusinessId:  businessId,
+		AddressType: r.URL.Query().Get("address_type"),
+		HD:          hd,
 	}
 
-	addrRet, err := h.svc.GetWalletAddress(cr)
+	addrRet, err := h.svc.GetWalletAddress(wr)
 	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	err = jsonResponse(w, addrRet, http.StatusOK)
 	if err != nil {
-		fmt.Println("Error writing response", "err", err.Error())
+		log.Error("error writing response", "err", err)
+	}
+}
+
+// ListKeys handles the HTTP request to page through the keys issued to a business id.
+// It extracts the 'business_id', 'page' and 'page_size' parameters from the query string,
+// constructs a KeysRequest, and calls the service's ListKeys method. Missing paging
+// parameters fall back to the service defaults and a missing business id to the business of
+// the API key; another business id is rejected with a 403. Malformed paging parameters, an
+// explicit page 0 and paging parameters above database.MaxPage or database.MaxPageSize are
+// rejected with a 400.
+//
+// Parameters:
+//   - w: The http.ResponseWriter used to write the JSON response.
+//   - r: The http.Request containing the business id and paging query parameters.
+func (h Routes) ListKeys(w http.ResponseWriter, r *http.Request) {
+	businessId, err := requestBusinessId(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page, err := parseUintParam(r, "page")
+	if err != nil || (page == 0 && r.URL.Query().Has("page")) {
+		writeError(w, r, service.NewValidationError("invalid page"))
+		return
+	}
+	pageSize, err := parseUintParam(r, "page_size")
+	if err != nil {
+		writeError(w, r, service.NewValidationError("invalid page_size"))
+		return
+	}
+	if err := database.ValidatePagination(page, pageSize); err != nil {
+		writeError(w, r, service.NewValidationError(err.Error()))
+		return
+	}
+	kr := &models.KeysRequest{
+		BusinessId: businessId,
+		Page:       page,
+		PageSize:   pageSize,
+	}
+
+	keysRet, err := h.svc.ListKeys(kr)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = jsonResponse(w, keysRet, http.StatusOK)
+	if err != nil {
+		log.Error("error writing response", "err", err)
+	}
+}
+
+// parseUintParam reads an optional unsigned integer query parameter.
+// An absent parameter yields 0.
+func parseUintParam(r *http.Request, name string) (uint64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
 	}
+	return strconv.ParseBool(value)
 }
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rest/service"
+)
+
+// TestListKeysPaging checks that out of range paging parameters are rejected
+// before the keys are queried.
+func TestListKeysPaging(t *testing.T) {
+	db, err := database.NewDB(context.Background(), config.DBConfig{Driver: database.DriverSQLite, Path: database.SQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.PrepareSchema(""); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := auth.IssueToken(db.Tokens, "merchant-1", auth.AllScopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := service.NewHandleSrv(service.NewValidator(chains.DefaultRegistry()), db.KeysView, nil, nil)
+	r := chi.NewRouter()
+	h := NewRoutes(r, svc)
+	r.Use(middleware.RequestID)
+	r.Use(APIKeyAuth(auth.NewAuthenticator(db.Tokens)))
+	r.With(RequireScope(auth.ScopeKeysRead)).Get("/keys", h.ListKeys)
+
+	for _, tt := range []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"defaults", "", http.StatusOK},
+		{"first page", "?page=1&page_size=" + strconv.FormatUint(database.MaxPageSize, 10), http.StatusOK},
+		{"page zero", "?page=0", http.StatusBadRequest},
+		{"page above max", "?page=" + strconv.FormatUint(database.MaxPage+1, 10), http.StatusBadRequest},
+		{"page overflow", "?page=18446744073709551615", http.StatusBadRequest},
+		{"page size above max", "?page_size=" + strconv.FormatUint(database.MaxPageSize+1, 10), http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/keys"+tt.query, nil)
+		req.Header.Set(APIKeyHeader, token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body.String())
+		}
+	}
+}
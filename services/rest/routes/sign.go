@@ -0,0 +1,123 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	models "github.com/qiaopengjun5162/go-rpc-service/services/rest/model"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rest/service"
+)
+
+// SignTransaction handles the HTTP request to sign an unsigned Ethereum transaction.
+// It decodes a SignTransactionRequest from the JSON body and calls the service's
+// SignTransaction method. The raw signed transaction and its hash are returned in a
+// JSON response. Malformed requests are rejected with a 400, keys of another business
+// than the API key's with a 403, unknown keys with a 404 and signing failures with a 500.
+//
+// Parameters:
+//   - w: The http.ResponseWriter used to write the JSON response.
+//   - r: The http.Request whose body holds the transaction to be signed.
+func (h Routes) SignTransaction(w http.ResponseWriter, r *http.Request) {
+	var req models.SignTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewValidationError("invalid request body"))
+		return
+	}
+	req.BusinessId = callerBusinessId(r)
+
+	signRet, err := h.svc.SignTransaction(&req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = jsonResponse(w, signRet, http.StatusOK)
+	if err != nil {
+		log.Error("error writing response", "err", err)
+	}
+}
+
+// SignMessage handles the HTTP request to sign an EIP-191 personal_sign message.
+// It decodes a SignMessageRequest from the JSON body and calls the service's
+// SignMessage method. The signature and signed hash are returned in a JSON response.
+//
+// Parameters:
+//   - w: The http.ResponseWriter used to write the JSON response.
+//   - r: The http.Request whose body holds the message to be signed.
+func (h Routes) SignMessage(w http.ResponseWriter, r *http.Request) {
+	var req models.SignMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewValidationError("invalid request body"))
+		return
+	}
+	req.BusinessId = callerBusinessId(r)
+
+	sigRet, err := h.svc.SignMessage(&req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = jsonResponse(w, sigRet, http.StatusOK)
+	if err != nil {
+		log.Error("error writing response", "err", err)
+	}
+}
+
+// SignTypedData handles the HTTP request to sign an EIP-712 typed data document.
+// It decodes a SignTypedDataRequest from the JSON body, whose typedData field holds
+// the eth_signTypedData_v4 document, and calls the service's SignTypedData method.
+// The signature and EIP-712 hash are returned in a JSON response.
+//
+// Parameters:
+//   - w: The http.ResponseWriter used to write the JSON response.
+//   - r: The http.Request whose body holds the typed data to be signed.
+func (h Routes) SignTypedData(w http.ResponseWriter, r *http.Request) {
+	var req models.SignTypedDataRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewValidationError("invalid request body"))
+		return
+	}
+	req.BusinessId = callerBusinessId(r)
+
+	sigRet, err := h.svc.SignTypedData(&req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = jsonResponse(w, sigRet, http.StatusOK)
+	if err != nil {
+		log.Error("error writing response", "err", err)
+	}
+}
+
+// SignPsbt handles the HTTP request to sign a BIP-174 PSBT.
+// It decodes a SignPsbtRequest from the JSON body and calls the service's SignPsbt
+// method. The updated PSBT and the signed and skipped inputs are returned in a JSON
+// response.
+//
+// Parameters:
+//   - w: The http.ResponseWriter used to write the JSON response.
+//   - r: The http.Request whose body holds the base64 PSBT and its network.
+func (h Routes) SignPsbt(w http.ResponseWriter, r *http.Request) {
+	var req models.SignPsbtRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, service.NewValidationError("invalid request body"))
+		return
+	}
+	req.BusinessId = callerBusinessId(r)
+
+	psbtRet, err := h.svc.SignPsbt(&req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = jsonResponse(w, psbtRet, http.StatusOK)
+	if err != nil {
+		log.Error("error writing response", "err", err)
+	}
+}
@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
+	"github.com/qiaopengjun5162/go-rpc-service/services/rest/service"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
+)
+
+// TestSignOtherBusinessKey checks that an API key of one business cannot sign
+// with the keys of another.
+func TestSignOtherBusinessKey(t *testing.T) {
+	db, err := database.NewDB(context.Background(), config.DBConfig{Driver: database.DriverSQLite, Path: database.SQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.PrepareSchema(""); err != nil {
+		t.Fatal(err)
+	}
+	keyCipher, err := envelope.NewCipher(make([]byte, 32), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	is := issuer.NewIssuer(db, keyCipher, nil, issuer.PoolConfig{})
+	address, err := is.IssueAddress(&issuer.AddressRequest{BusinessId: "merchant-a", Chain: chains.Ethereum, Network: chains.MainNet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenA, _, err := auth.IssueToken(db.Tokens, "merchant-a", auth.AllScopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenB, _, err := auth.IssueToken(db.Tokens, "merchant-b", auth.AllScopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := service.NewHandleSrv(service.NewValidator(chains.DefaultRegistry()), db.KeysView, is, signer.NewSigner(db.KeysView, db.Keys, keyCipher))
+	r := chi.NewRouter()
+	h := NewRoutes(r, svc)
+	r.Use(middleware.RequestID)
+	r.Use(APIKeyAuth(auth.NewAuthenticator(db.Tokens)))
+	r.With(RequireScope(auth.ScopeSign)).Post("/sign/message", h.SignMessage)
+
+	for _, tt := range []struct {
+		name   string
+		apiKey string
+		status int
+	}{
+		{"other business", tokenB, http.StatusForbidden},
+		{"owner", tokenA, http.StatusOK},
+	} {
+		body := `{"address":"` + address.Address + `","message":"hello"}`
+		req := httptest.NewRequest(http.MethodPost, "/sign/message", strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, tt.apiKey)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
+)
+
+// Error codes of the rest api error envelope.
+const (
+	CodeValidation   = "validation_error"
+	CodeNotFound     = "not_found"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeInternal     = "internal_error"
+)
+
+// internalMessage is returned to the client for every internal error; the
+// cause is only logged.
+const internalMessage = "Internal server error"
+
+// Error is a service error with the code it is reported under.
+type Error struct {
+	Code    string
+	Message string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil && e.Code == CodeInternal {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewValidationError reports a request that cannot be served as sent.
+func NewValidationError(message string) *Error {
+	return &Error{Code: CodeValidation, Message: message}
+}
+
+// NewNotFoundError reports a request for something that does not exist.
+func NewNotFoundError(message string) *Error {
+	return &Error{Code: CodeNotFound, Message: message}
+}
+
+// NewUnauthorizedError reports a request without usable credentials.
+func NewUnauthorizedError(message string) *Error {
+	return &Error{Code: CodeUnauthorized, Message: message}
+}
+
+// NewForbiddenError reports a request the credentials do not allow.
+func NewForbiddenError(message string) *Error {
+	return &Error{Code: CodeForbidden, Message: message}
+}
+
+// NewInternalError reports a failure of the service itself. The client only
+// sees a generic message.
+func NewInternalError(err error) *Error {
+	return &Error{Code: CodeInternal, Message: internalMessage, Err: err}
+}
+
+// AsError classifies err into a service Error. Errors of the issuer, signer
+// and auth packages map onto their code, anything unknown is internal.
+func AsError(err error) *Error {
+	var svcErr *Error
+	switch {
+	case errors.As(err, &svcErr):
+		return svcErr
+	case issuer.IsInvalidRequest(err), signer.IsInvalidRequest(err),
+		errors.Is(err, database.ErrInvalidPage), errors.Is(err, database.ErrInvalidPageSize):
+		return &Error{Code: CodeValidation, Message: err.Error(), Err: err}
+	case errors.Is(err, signer.ErrKeyNotFound):
+		return &Error{Code: CodeNotFound, Message: err.Error(), Err: err}
+	case errors.Is(err, signer.ErrKeyNotOwned):
+		return &Error{Code: CodeForbidden, Message: err.Error(), Err: err}
+	case auth.IsUnauthenticated(err):
+		return &Error{Code: CodeUnauthorized, Message: err.Error(), Err: err}
+	default:
+		return NewInternalError(err)
+	}
+}
@@ -2,33 +2,61 @@ package service
 
 import (
 	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
 	models "github.com/qiaopengjun5162/go-rpc-service/services/rest/model"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
 )
 
+// Service is the rest api service layer. Every error it returns is an *Error
+// carrying the code the error is reported under.
 type Service interface {
 	// GetSupportCoins returns whether the service supports the given chain and network.
 	// A "support" response indicates that the service supports the given chain and network.
 	// A "not support" response indicates that the service does not support the given chain and network.
 	GetSupportCoins(*models.ChainRequest) (*models.SupportChainResponse, error)
-	// GetWalletAddress returns the wallet address for the given chain and network.
+	// ListSupportedChains returns every chain of the chain registry with its
+	// networks, curve, address formats and derivation path.
+	ListSupportedChains() (*models.SupportedChainsResponse, error)
+	// GetWalletAddress issues a new wallet address for the given chain, network and business id.
 	// The response object contains the public key and address.
-	GetWalletAddress(*models.ChainRequest) (*models.WalletAddressResponse, error)
+	GetWalletAddress(*models.WalletAddressRequest) (*models.WalletAddressResponse, error)
+	// ListKeys returns one page of the keys issued to a business id.
+	// Private key material is never part of the response.
+	ListKeys(*models.KeysRequest) (*models.KeysResponse, error)
+	// SignTransaction signs an unsigned Ethereum transaction with the stored key
+	// for the requested public key or address.
+	SignTransaction(*models.SignTransactionRequest) (*models.SignTransactionResponse, error)
+	// SignMessage signs an EIP-191 personal_sign message with the stored key
+	// for the requested public key or address.
+	SignMessage(*models.SignMessageRequest) (*models.SignatureResponse, error)
+	// SignTypedData signs an EIP-712 typed data document with the stored key
+	// for the requested public key or address.
+	SignTypedData(*models.SignTypedDataRequest) (*models.SignatureResponse, error)
+	// SignPsbt signs the inputs of a BIP-174 PSBT whose keys are stored in the
+	// keys table.
+	SignPsbt(*models.SignPsbtRequest) (*models.SignPsbtResponse, error)
 }
 
 type HandleSrv struct {
 	v        *Validator
 	keysView database.KeysView
+	issuer   *issuer.Issuer
+	signer   *signer.Signer
 }
 
-func NewHandleSrv(v *Validator, ksv database.KeysView) Service {
+func NewHandleSrv(v *Validator, ksv database.KeysView, is *issuer.Issuer, sn *signer.Signer) Service {
 	return &HandleSrv{
 		v:        v,
 		keysView: ksv,
+		issuer:   is,
+		signer:   sn,
 	}
 }
 
 // GetSupportCoins checks if the specified blockchain and network are supported.
-// It utilizes the Validator to verify the chain and network parameters.
+// It utilizes the Validator to verify the chain and network parameters against
+// the chain registry, matching names and aliases case-insensitively.
 // Returns a SupportChainResponse indicating whether the chain and network are supported.
 // If supported, the Support field in the response will be true, otherwise false.
 //
@@ -51,21 +79,281 @@ func (h HandleSrv) GetSupportCoins(req *models.ChainRequest) (*models.SupportCha
 	}
 }
 
-// GetWalletAddress generates a wallet address and associated public key for the
-// specified blockchain and network. The generated address and public key are
-// returned in a WalletAddressResponse object.
+// ListSupportedChains lists the chains of the chain registry, ordered by name.
+//
+// Returns:
+//   - A pointer to a SupportedChainsResponse object listing every chain with
+//     its networks, curve, address formats and derivation path.
+//   - An error if any occurs during the process.
+func (h HandleSrv) ListSupportedChains() (*models.SupportedChainsResponse, error) {
+	chainList := h.v.Chains()
+	out := make([]models.ChainInfo, 0, len(chainList))
+	for _, c := range chainList {
+		networks := make([]models.NetworkInfo, 0, len(c.Networks))
+		for _, n := range c.Networks {
+			networks = append(networks, models.NetworkInfo{
+				Name:           n.Name,
+				Aliases:        n.Aliases,
+				ChainId:        n.ChainId,
+				Params:         n.Params,
+				DerivationPath: n.DerivationPath,
+			})
+		}
+		out = append(out, models.ChainInfo{
+			Name:           c.Name,
+			Aliases:        c.Aliases,
+			Curve:          c.Curve,
+			AddressFormats: c.AddressFormats,
+			DerivationPath: c.DerivationPath,
+			Networks:       networks,
+		})
+	}
+	return &models.SupportedChainsResponse{Chains: out}, nil
+}
+
+// GetWalletAddress issues a wallet address and associated public key for the
+// specified blockchain and network. Chain and network names are resolved to
+// their canonical names first, so aliases such as "eth" or "mainnet" are
+// accepted. The key pair is generated and stored by
+// the issuer shared with the rpc service, so both transports return the same
+// kind of address.
 //
 // Parameters:
-//   - req: A pointer to a ChainRequest object containing the chain and network
-//     for which the address and public key should be generated.
+//   - req: A pointer to a WalletAddressRequest object containing the chain,
+//     network, business id and optional Bitcoin address type for which the
+//     address should be issued, and whether it is derived from the business
+//     HD seed.
 //
 // Returns:
 //   - A pointer to a WalletAddressResponse object containing the generated
 //     address and public key.
-//   - An error if any occurs during the process.
-func (h HandleSrv) GetWalletAddress(*models.ChainRequest) (*models.WalletAddressResponse, error) {
+//   - An error if the chain and network are not supported, or if the key
+//     could not be generated or stored.
+func (h HandleSrv) GetWalletAddress(req *models.WalletAddressRequest) (*models.WalletAddressResponse, error) {
+	chain, network, err := h.v.ResolveChain(req.Chain, req.Network)
+	if err != nil {
+		return nil, AsError(err)
+	}
+	addressInfo, err := h.issuer.IssueAddress(&issuer.AddressRequest{
+		BusinessId:  req.BusinessId,
+		Chain:       chain,
+		Network:     network,
+		AddressType: req.AddressType,
+		HD:          req.HD,
+	})
+	if err != nil {
+		return nil, AsError(err)
+	}
 	return &models.WalletAddressResponse{
-		PublicKey: "public key",
-		Address:   "0x00000000000000000000000",
+		PublicKey:       addressInfo.PublicKey,
+		Address:         addressInfo.Address,
+		AddressType:     addressInfo.AddressType,
+		DerivationPath:  addressInfo.DerivationPath,
+		DerivationIndex: addressInfo.DerivationIndex,
+	}, nil
+}
+
+// ListKeys returns one page of the keys issued to the requested business id.
+//
+// Paging follows database.NormalizePagination and the effective page and page
+// size are echoed back in the response. Only the public part of each key is
+// returned, together with the address derived from it.
+//
+// Parameters:
+//   - req: A pointer to a KeysRequest object containing the business id and
+//     the page to be returned.
+//
+// Returns:
+//   - A pointer to a KeysResponse object containing the page of keys and the
+//     total number of keys for the business id.
+//   - An error if the business id is empty, the page is rejected by
+//     database.ValidatePagination or the query fails.
+func (h HandleSrv) ListKeys(req *models.KeysRequest) (*models.KeysResponse, error) {
+	if req.BusinessId == "" {
+		return nil, NewValidationError("business_id is required")
+	}
+	page, pageSize := database.NormalizePagination(req.Page, req.PageSize)
+	keyList, total, err := h.keysView.QueryKeysByBusId(req.BusinessId, page, pageSize)
+	if err != nil {
+		return nil, AsError(err)
+	}
+	keys := make([]models.KeyInfo, 0, len(keyList))
+	for _, k := range keyList {
+		address, err := issuer.KeyAddress(k)
+		if err != nil {
+			return nil, AsError(err)
+		}
+		keys = append(keys, models.KeyInfo{
+			Guid:           k.GUID.String(),
+			BusinessId:     k.BusinessId,
+			PublicKey:      k.PublicKey,
+			Address:        address,
+			Timestamp:      k.Timestamp,
+			Chain:          k.Chain,
+			Network:        k.Network,
+			AddressType:    k.AddressType,
+			DerivationPath: k.DerivationPath,
+		})
+	}
+	return &models.KeysResponse{
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+		Keys:     keys,
+	}, nil
+}
+
+// SignTransaction signs an unsigned Ethereum transaction with the stored key
+// for the requested public key or address. Only keys of req.BusinessId, the
+// business of the API key, are used.
+//
+// Parameters:
+//   - req: A pointer to a SignTransactionRequest object describing the legacy,
+//     EIP-2930 or EIP-1559 transaction and the key to sign it with.
+//
+// Returns:
+//   - A pointer to a SignTransactionResponse object containing the raw signed
+//     transaction and its hash.
+//   - An error if the request is invalid, the key is unknown or belongs to
+//     another business than req.BusinessId, or signing fails.
+func (h HandleSrv) SignTransaction(req *models.SignTransactionRequest) (*models.SignTransactionResponse, error) {
+	accessList := make([]signer.AccessTuple, 0, len(req.AccessList))
+	for _, tuple := range req.AccessList {
+		accessList = append(accessList, signer.AccessTuple{
+			Address:     tuple.Address,
+			StorageKeys: tuple.StorageKeys,
+		})
+	}
+	signedTx, err := h.signer.SignTransaction(&signer.TransactionRequest{
+		BusinessId: req.BusinessId,
+		PublicKey:  req.PublicKey,
+		Address:    req.Address,
+		ChainId:    req.ChainId,
+		Type:       req.Type,
+		Nonce:      req.Nonce,
+		To:         req.To,
+		Value:      req.Value,
+		Gas:        req.Gas,
+		GasPrice:   req.GasPrice,
+		GasTipCap:  req.MaxPriorityFeePerGas,
+		GasFeeCap:  req.MaxFeePerGas,
+		Data:       req.Data,
+		AccessList: accessList,
+	})
+	if err != nil {
+		return nil, AsError(err)
+	}
+	return &models.SignTransactionResponse{
+		SignedTx: signedTx.RawTx,
+		TxHash:   signedTx.TxHash,
 	}, nil
 }
+
+// SignMessage signs an EIP-191 personal_sign message with the stored key for
+// the requested public key or address. A 0x prefixed hex message is signed as
+// raw bytes, anything else as UTF-8 text.
+//
+// Parameters:
+//   - req: A pointer to a SignMessageRequest object holding the message and
+//     the key to sign it with.
+//
+// Returns:
+//   - A pointer to a SignatureResponse object containing the 65 byte r||s||v
+//     signature and the signed hash.
+//   - An error if the request is invalid, the key is unknown or belongs to
+//     another business than req.BusinessId, or signing fails.
+func (h HandleSrv) SignMessage(req *models.SignMessageRequest) (*models.SignatureResponse, error) {
+	sig, err := h.signer.SignMessage(&signer.MessageRequest{
+		BusinessId: req.BusinessId,
+		PublicKey:  req.PublicKey,
+		Address:    req.Address,
+		Message:    req.Message,
+	})
+	if err != nil {
+		return nil, AsError(err)
+	}
+	return &models.SignatureResponse{
+		Signature: sig.Signature,
+		Hash:      sig.Hash,
+	}, nil
+}
+
+// SignTypedData signs an EIP-712 typed data document with the stored key for
+// the requested public key or address. The domain's chain id must match the
+// requested network.
+//
+// Parameters:
+//   - req: A pointer to a SignTypedDataRequest object holding the typed data,
+//     the network and the key to sign it with.
+//
+// Returns:
+//   - A pointer to a SignatureResponse object containing the 65 byte r||s||v
+//     signature and the EIP-712 hash.
+//   - An error if the request is invalid, the key is unknown or belongs to
+//     another business than req.BusinessId, or signing fails.
+func (h HandleSrv) SignTypedData(req *models.SignTypedDataRequest) (*models.SignatureResponse, error) {
+	network, err := h.v.Network(chains.Ethereum, req.Network)
+	if err != nil {
+		return nil, AsError(err)
+	}
+	sig, err := h.signer.SignTypedData(&signer.TypedDataRequest{
+		BusinessId: req.BusinessId,
+		PublicKey:  req.PublicKey,
+		Address:    req.Address,
+		Network:    network.Name,
+		ChainId:    network.ChainId,
+		TypedData:  req.TypedData,
+	})
+	if err != nil {
+		return nil, AsError(err)
+	}
+	return &models.SignatureResponse{
+		Signature: sig.Signature,
+		Hash:      sig.Hash,
+	}, nil
+}
+
+// SignPsbt signs the P2WPKH and P2TR key path inputs of a base64 PSBT whose
+// keys are stored in the keys table for req.BusinessId. Inputs are not
+// finalized.
+//
+// Parameters:
+//   - req: A pointer to a SignPsbtRequest object holding the PSBT and the
+//     Bitcoin network it spends on.
+//
+// Returns:
+//   - A pointer to a SignPsbtResponse object containing the updated PSBT, the
+//     inputs that were signed and the inputs that were skipped with a reason.
+//   - An error if the PSBT or network is invalid or a key cannot be used.
+func (h HandleSrv) SignPsbt(req *models.SignPsbtRequest) (*models.SignPsbtResponse, error) {
+	network, err := h.v.Network(chains.Bitcoin, req.Network)
+	if err != nil {
+		return nil, AsError(err)
+	}
+	signed, err := h.signer.SignPsbt(&signer.PsbtRequest{
+		BusinessId: req.BusinessId,
+		Network:    network.Name,
+		Params:     network.Params,
+		Psbt:       req.Psbt,
+	})
+	if err != nil {
+		return nil, AsError(err)
+	}
+	return &models.SignPsbtResponse{
+		Psbt:          signed.Psbt,
+		SignedInputs:  toPsbtInputs(signed.Signed),
+		SkippedInputs: toPsbtInputs(signed.Skipped),
+	}, nil
+}
+
+func toPsbtInputs(inputs []signer.PsbtInput) []models.PsbtInput {
+	out := make([]models.PsbtInput, 0, len(inputs))
+	for _, input := range inputs {
+		out = append(out, models.PsbtInput{
+			Index:   input.Index,
+			Address: input.Address,
+			Reason:  input.Reason,
+		})
+	}
+	return out
+}
@@ -1,17 +1,55 @@
 package service
 
-type Validator struct{}
+import (
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+// Validator checks the chain and network of rest requests against the chain
+// registry shared with the rpc service.
+type Validator struct {
+	registry *chains.Registry
+}
+
+// NewValidator returns a Validator backed by the given chain registry.
+//
+// Parameters:
+//   - registry: The chain registry to validate against.
+//
+// Returns:
+//   - *Validator: The validator.
+func NewValidator(registry *chains.Registry) *Validator {
+	return &Validator{registry: registry}
+}
 
 // VerifyWalletAddress verify the chain and network is valid or not
 //
-// only support Bitcoin and Ethereum for now
-// only support MainNet and TestNet for now
+// Names and aliases are matched case-insensitively, see chains.Registry.
 func (v *Validator) VerifyWalletAddress(chain, network string) bool {
-	if chain != "Bitcoin" && chain != "Ethereum" {
-		return false
-	}
-	if network != "MainNet" && network != "TestNet" {
-		return false
-	}
-	return true
+	return v.registry.Supports(chain, network)
+}
+
+// ResolveChain returns the canonical chain and network names of a request.
+//
+// Returns:
+//   - string: The canonical chain name.
+//   - string: The canonical network name.
+//   - error: chains.ErrUnsupportedChain or chains.ErrUnsupportedNetwork.
+func (v *Validator) ResolveChain(chain, network string) (string, string, error) {
+	return v.registry.Resolve(chain, network)
+}
+
+// Chains returns the chains of the registry ordered by name.
+func (v *Validator) Chains() []chains.Chain {
+	return v.registry.Chains()
+}
+
+// ResolveNetwork returns the canonical name of a network of the given chain.
+func (v *Validator) ResolveNetwork(chain, network string) (string, error) {
+	return v.registry.ResolveNetwork(chain, network)
+}
+
+// Network returns a network of the given chain as configured in the registry,
+// including its chain id.
+func (v *Validator) Network(chain, network string) (chains.Network, error) {
+	return v.registry.Network(chain, network)
 }
@@ -0,0 +1,156 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/qiaopengjun5162/go-rpc-service/protobuf/wallet"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+)
+
+// ConsumerTokenMetadataKey is the metadata key a consumer token may be sent
+// under instead of the consumer_token request field.
+const ConsumerTokenMetadataKey = "consumer-token"
+
+type consumerTokenRequest interface {
+	GetConsumerToken() string
+}
+
+type businessRequest interface {
+	GetBusinessId() string
+}
+
+// walletMethodPrefix selects the methods that require a consumer token;
+// reflection and other registered services are left open.
+var walletMethodPrefix = "/" + wallet.WalletService_ServiceDesc.ServiceName + "/"
+
+// methodScopes maps the wallet service methods to the scope a token needs to
+// call them. Methods that are not listed only need a valid token.
+var methodScopes = map[string]string{
+	wallet.WalletService_GetWalletAddress_FullMethodName:     auth.ScopeAddressCreate,
+	wallet.WalletService_BatchCreateAddresses_FullMethodName: auth.ScopeAddressCreate,
+	wallet.WalletService_ListKeys_FullMethodName:             auth.ScopeKeysRead,
+	wallet.WalletService_SignTransaction_FullMethodName:      auth.ScopeSign,
+	wallet.WalletService_SignMessage_FullMethodName:          auth.ScopeSign,
+	wallet.WalletService_SignTypedData_FullMethodName:        auth.ScopeSign,
+	wallet.WalletService_SignPsbt_FullMethodName:             auth.ScopeSign,
+}
+
+// authUnaryInterceptor authenticates the consumer token of every wallet
+// service call before the handler runs and stores the credential in the
+// handler's context.
+func (s *RpcServer) authUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !strings.HasPrefix(info.FullMethod, walletMethodPrefix) {
+		return handler(ctx, req)
+	}
+	ctx, err := s.authenticate(ctx, info.FullMethod, req)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStreamInterceptor authenticates server-streaming wallet service calls.
+// The token travels in the request message, so it is checked as soon as the
+// message is received, before the handler sees it.
+func (s *RpcServer) authStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !strings.HasPrefix(info.FullMethod, walletMethodPrefix) {
+		return handler(srv, ss)
+	}
+	return handler(srv, &authServerStream{ServerStream: ss, server: s, method: info.FullMethod, ctx: ss.Context()})
+}
+
+// authenticate resolves the consumer token of req, falling back to the
+// ConsumerTokenMetadataKey metadata, and rejects a request whose token lacks
+// the scope of the method or whose business id differs from the one the
+// token is bound to.
+func (s *RpcServer) authenticate(ctx context.Context, method string, req any) (context.Context, error) {
+	var token string
+	if r, ok := req.(consumerTokenRequest); ok {
+		token = r.GetConsumerToken()
+	}
+	if token == "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(ConsumerTokenMetadataKey); len(values) > 0 {
+				token = values[0]
+			}
+		}
+	}
+	cred, err := s.auth.Authenticate(token)
+	if err != nil {
+		return nil, statusError(err, "authenticate fail")
+	}
+	if scope, ok := methodScopes[method]; ok && !cred.HasScope(scope) {
+		return nil, newStatus(codes.PermissionDenied, "MISSING_SCOPE", "", "consumer token lacks scope "+scope)
+	}
+	if r, ok := req.(businessRequest); ok {
+		if _, err := ownBusinessId(cred, r.GetBusinessId()); err != nil {
+			return nil, err
+		}
+	}
+	return auth.NewContext(ctx, cred), nil
+}
+
+// authServerStream authenticates the first message received on a stream and
+// exposes the resulting credential through Context.
+type authServerStream struct {
+	grpc.ServerStream
+	server        *RpcServer
+	method        string
+	ctx           context.Context
+	authenticated bool
+}
+
+func (ss *authServerStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (ss *authServerStream) RecvMsg(m any) error {
+	if err := ss.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if ss.authenticated {
+		return nil
+	}
+	ctx, err := ss.server.authenticate(ss.ctx, ss.method, m)
+	if err != nil {
+		return err
+	}
+	ss.ctx = ctx
+	ss.authenticated = true
+	return nil
+}
+
+// callerBusinessId returns the business id of the consumer token the auth
+// interceptors stored in ctx.
+func callerBusinessId(ctx context.Context) (string, error) {
+	cred, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", auth.ErrTokenRequired
+	}
+	return cred.BusinessId, nil
+}
+
+// requestBusinessId returns the business id a key-touching handler acts on:
+// the business of the consumer token in ctx. A requested business id is only
+// accepted if it is the token's; an empty one defaults to it. Handlers check
+// this themselves rather than relying on the interceptor having seen a
+// business_id field.
+func requestBusinessId(ctx context.Context, requested string) (string, error) {
+	cred, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", statusError(auth.ErrTokenRequired, "authenticate fail")
+	}
+	return ownBusinessId(cred, requested)
+}
+
+func ownBusinessId(cred *auth.Credential, requested string) (string, error) {
+	if requested != "" && requested != cred.BusinessId {
+		return "", newStatus(codes.PermissionDenied, "BUSINESS_ID_MISMATCH", "", "business_id does not match consumer token")
+	}
+	return cred.BusinessId, nil
+}
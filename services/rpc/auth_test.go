@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/qiaopengjun5162/go-rpc-service/protobuf/wallet"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+)
+
+func TestAuthUnaryInterceptor(t *testing.T) {
+	s := newSQLiteServer(t)
+	ctx := issueToken(t, s, "merchant-a")
+	reader, _, err := auth.IssueToken(s.db.Tokens, "merchant-a", []string{auth.ScopeKeysRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readerCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ConsumerTokenMetadataKey, reader))
+
+	for _, tt := range []struct {
+		name   string
+		ctx    context.Context
+		method string
+		req    any
+		code   codes.Code
+	}{
+		{"missing token", context.Background(), wallet.WalletService_ListKeys_FullMethodName, &wallet.ListKeysRequest{}, codes.Unauthenticated},
+		{"token field", context.Background(), wallet.WalletService_ListKeys_FullMethodName, &wallet.ListKeysRequest{ConsumerToken: reader}, codes.OK},
+		{"missing scope", readerCtx, wallet.WalletService_SignMessage_FullMethodName, &wallet.SignMessageRequest{}, codes.PermissionDenied},
+		{"other business", ctx, wallet.WalletService_ListKeys_FullMethodName, &wallet.ListKeysRequest{BusinessId: "merchant-b"}, codes.PermissionDenied},
+		{"own business", ctx, wallet.WalletService_ListKeys_FullMethodName, &wallet.ListKeysRequest{BusinessId: "merchant-a"}, codes.OK},
+	} {
+		_, err := s.authUnaryInterceptor(tt.ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req any) (any, error) {
+			if businessId, err := callerBusinessId(ctx); err != nil || businessId != "merchant-a" {
+				t.Errorf("%s: handler got business %q, %v", tt.name, businessId, err)
+			}
+			return nil, nil
+		})
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.code)
+		}
+	}
+}
+
+// fakeServerStream receives req once and records the messages sent.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	req  proto.Message
+	sent []any
+}
+
+func (f *fakeServerStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeServerStream) RecvMsg(m any) error {
+	proto.Merge(m.(proto.Message), f.req)
+	return nil
+}
+
+func (f *fakeServerStream) SendMsg(m any) error {
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func TestAuthStreamInterceptor(t *testing.T) {
+	s := newSQLiteServer(t)
+	ctx := issueToken(t, s, "merchant-a")
+	info := &grpc.StreamServerInfo{FullMethod: wallet.WalletService_BatchCreateAddresses_FullMethodName, IsServerStream: true}
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		req  *wallet.BatchCreateAddressesRequest
+		code codes.Code
+	}{
+		{"missing token", context.Background(), &wallet.BatchCreateAddressesRequest{BusinessId: "merchant-a"}, codes.Unauthenticated},
+		{"other business", ctx, &wallet.BatchCreateAddressesRequest{BusinessId: "merchant-b"}, codes.PermissionDenied},
+		{"own business", ctx, &wallet.BatchCreateAddressesRequest{BusinessId: "merchant-a", Chain: "Ethereum", Network: "MainNet", Count: 2}, codes.OK},
+	} {
+		stream := &fakeServerStream{ctx: tt.ctx, req: tt.req}
+		err := s.authStreamInterceptor(s, stream, info, func(srv any, ss grpc.ServerStream) error {
+			in := new(wallet.BatchCreateAddressesRequest)
+			if err := ss.RecvMsg(in); err != nil {
+				return err
+			}
+			return s.BatchCreateAddresses(in, &walletServiceBatchCreateAddressesServer{ss})
+		})
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.code)
+		}
+		if tt.code == codes.OK && len(stream.sent) == 0 {
+			t.Errorf("%s: no addresses streamed", tt.name)
+		}
+	}
+}
+
+// walletServiceBatchCreateAddressesServer adapts a grpc.ServerStream to the
+// generated stream interface, as the generated handler does.
+type walletServiceBatchCreateAddressesServer struct {
+	grpc.ServerStream
+}
+
+func (x *walletServiceBatchCreateAddressesServer) Send(m *wallet.BatchCreateAddressesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// TestHandlersCheckBusiness calls the key-touching handlers directly, without
+// the interceptor, with a credential of another business.
+func TestHandlersCheckBusiness(t *testing.T) {
+	s := newSQLiteServer(t)
+	ctx := auth.NewContext(context.Background(), &auth.Credential{BusinessId: "merchant-b"})
+
+	_, err := s.ListKeys(ctx, &wallet.ListKeysRequest{BusinessId: "merchant-a"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("list keys: got %v, want PermissionDenied", err)
+	}
+	_, err = s.GetWalletAddress(ctx, &wallet.WalletAddressRequest{BusinessId: "merchant-a", Chain: "Ethereum", Network: "MainNet"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("wallet address: got %v, want PermissionDenied", err)
+	}
+	_, err = s.ListKeys(context.Background(), &wallet.ListKeysRequest{BusinessId: "merchant-a"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("no credential: got %v, want Unauthenticated", err)
+	}
+	keys, err := s.ListKeys(ctx, &wallet.ListKeysRequest{})
+	if err != nil || keys.Total != 0 {
+		t.Errorf("default business: got %v, %v", keys, err)
+	}
+}
@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
+)
+
+// ErrorDomain is the domain of the ErrorInfo detail attached to every error
+// status returned by the wallet service.
+const ErrorDomain = "go-signature"
+
+// Metadata keys of the ErrorInfo detail. LegacyCodeKey and LegacyMsgKey carry
+// the values the deprecated code and msg response fields would have held, for
+// clients that still read them.
+const (
+	LegacyCodeKey = "code"
+	LegacyMsgKey  = "msg"
+)
+
+// errorReasons names the known causes in the ErrorInfo detail.
+var errorReasons = []struct {
+	err    error
+	reason string
+	field  string
+}{
+	{issuer.ErrBusinessIdRequired, "BUSINESS_ID_REQUIRED", "business_id"},
+	{chains.ErrUnsupportedChain, "UNSUPPORTED_CHAIN", "chain"},
+	{chains.ErrUnsupportedNetwork, "UNSUPPORTED_NETWORK", "network"},
+	{issuer.ErrUnsupportedAddressType, "UNSUPPORTED_ADDRESS_TYPE", "address_type"},
+	{issuer.ErrInvalidCount, "INVALID_COUNT", "count"},
+	{database.ErrInvalidPage, "INVALID_PAGE", "page"},
+	{database.ErrInvalidPageSize, "INVALID_PAGE_SIZE", "page_size"},
+	{signer.ErrKeyRequired, "KEY_REQUIRED", "public_key"},
+	{signer.ErrInvalidRequest, "INVALID_SIGNING_REQUEST", ""},
+	{signer.ErrKeyNotFound, "KEY_NOT_FOUND", ""},
+	{signer.ErrKeyNotOwned, "KEY_NOT_OWNED", ""},
+	{signer.ErrKeyWrongChain, "KEY_WRONG_CHAIN", ""},
+	{auth.ErrTokenRequired, "TOKEN_REQUIRED", "consumer_token"},
+	{auth.ErrInvalidToken, "INVALID_TOKEN", "consumer_token"},
+	{auth.ErrTokenRevoked, "TOKEN_REVOKED", "consumer_token"},
+}
+
+// statusError maps err onto a gRPC status error with an ErrorInfo detail, and
+// a BadRequest detail naming the offending field for invalid arguments.
+//
+// Caller errors keep their message. Storage outages are reported as
+// Unavailable so clients can retry, and any other failure as Internal with
+// the generic message; the cause of both is logged, never returned.
+//
+// Parameters:
+//   - err: The error returned by the issuer, signer or authenticator.
+//   - message: The message reported for Unavailable and Internal errors.
+//
+// Returns:
+//   - error: The status error.
+func statusError(err error, message string) error {
+	code := codes.Internal
+	reason := "INTERNAL"
+	field := ""
+	switch {
+	case issuer.IsInvalidRequest(err), signer.IsInvalidRequest(err),
+		errors.Is(err, database.ErrInvalidPage), errors.Is(err, database.ErrInvalidPageSize):
+		code = codes.InvalidArgument
+	case errors.Is(err, signer.ErrKeyNotFound):
+		code = codes.NotFound
+	case errors.Is(err, signer.ErrKeyNotOwned):
+		code = codes.PermissionDenied
+	case auth.IsUnauthenticated(err):
+		code = codes.Unauthenticated
+	case isUnavailable(err):
+		code = codes.Unavailable
+		reason = "STORAGE_UNAVAILABLE"
+	}
+	if code == codes.Internal || code == codes.Unavailable {
+		log.Error(message, "code", code, "err", err)
+	} else {
+		message = err.Error()
+		for _, r := range errorReasons {
+			if errors.Is(err, r.err) {
+				reason, field = r.reason, r.field
+				break
+			}
+		}
+	}
+	return newStatus(code, reason, field, message)
+}
+
+// newStatus builds a status error with an ErrorInfo detail, and a BadRequest
+// detail if field is set.
+func newStatus(code codes.Code, reason, field, message string) error {
+	st := status.New(code, message)
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{
+			Reason: reason,
+			Domain: ErrorDomain,
+			Metadata: map[string]string{
+				LegacyCodeKey: strconv.Itoa(legacyCode(code)),
+				LegacyMsgKey:  message,
+			},
+		},
+	}
+	if field != "" && code == codes.InvalidArgument {
+		details = append(details, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: field, Description: message},
+			},
+		})
+	}
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// legacyCode returns the HTTP style code the code response field used to
+// carry for the status code.
+func legacyCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return 200
+	case codes.InvalidArgument:
+		return 400
+	case codes.Unauthenticated:
+		return 401
+	case codes.PermissionDenied:
+		return 403
+	case codes.NotFound:
+		return 404
+	case codes.Unavailable:
+		return 503
+	default:
+		return 500
+	}
+}
+
+// isUnavailable reports whether err means the database could not be reached.
+func isUnavailable(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.As(err, &opErr)
+}
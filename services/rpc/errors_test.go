@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		err        error
+		code       codes.Code
+		msg        string
+		reason     string
+		legacyCode string
+		field      string
+	}{
+		{issuer.ErrUnsupportedChain, codes.InvalidArgument, "unsupported chain", "UNSUPPORTED_CHAIN", "400", "chain"},
+		{fmt.Errorf("lookup: %w", signer.ErrKeyNotFound), codes.NotFound, "lookup: key not found", "KEY_NOT_FOUND", "404", ""},
+		{signer.ErrKeyNotOwned, codes.PermissionDenied, "key belongs to another business", "KEY_NOT_OWNED", "403", ""},
+		{fmt.Errorf("%w: Bitcoin/MainNet", signer.ErrKeyWrongChain), codes.InvalidArgument, "key was issued for another chain or network: Bitcoin/MainNet", "KEY_WRONG_CHAIN", "400", ""},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, codes.Unavailable, "op fail", "STORAGE_UNAVAILABLE", "503", ""},
+		{errors.New("disk on fire"), codes.Internal, "op fail", "INTERNAL", "500", ""},
+	}
+	for _, tt := range tests {
+		st := status.Convert(statusError(tt.err, "op fail"))
+		if st.Code() != tt.code || st.Message() != tt.msg {
+			t.Errorf("%v: got %s %q, want %s %q", tt.err, st.Code(), st.Message(), tt.code, tt.msg)
+			continue
+		}
+		var info *errdetails.ErrorInfo
+		var badRequest *errdetails.BadRequest
+		for _, detail := range st.Details() {
+			switch d := detail.(type) {
+			case *errdetails.ErrorInfo:
+				info = d
+			case *errdetails.BadRequest:
+				badRequest = d
+			}
+		}
+		if info == nil || info.Reason != tt.reason || info.Domain != ErrorDomain || info.Metadata[LegacyCodeKey] != tt.legacyCode {
+			t.Errorf("%v: unexpected error info %v", tt.err, info)
+		}
+		if tt.field == "" && badRequest != nil {
+			t.Errorf("%v: unexpected bad request detail", tt.err)
+		}
+		if tt.field != "" && (badRequest == nil || badRequest.FieldViolations[0].Field != tt.field) {
+			t.Errorf("%v: unexpected bad request detail %v", tt.err, badRequest)
+		}
+	}
+}
@@ -3,35 +3,344 @@ package rpc
 import (
 	"context"
 	"fmt"
-	"github.com/qiaopengjun5162/go-rpc-service/protobuf/wallet"
-	"github.com/qiaopengjun5162/go-rpc-service/services/addresses"
+	"math"
 	"strconv"
+
+	"github.com/ethereum/go-ethereum/log"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/protobuf/wallet"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
 )
 
+// GetSupportCoins reports whether the chain and network are in the chain
+// registry. Names and aliases are matched case-insensitively, as in every
+// other handler.
 func (s *RpcServer) GetSupportCoins(ctx context.Context, in *wallet.SupportCoinsRequest) (*wallet.SupportCoinsResponse, error) {
-
 	return &wallet.SupportCoinsResponse{
 		Code:    strconv.Itoa(200),
 		Msg:     "success request",
-		Support: true,
+		Support: s.chains.Supports(in.Chain, in.Network),
+	}, nil
+}
+
+// ListSupportedChains lists the chains of the chain registry, ordered by name,
+// with their networks, curve, address formats and derivation paths.
+func (s *RpcServer) ListSupportedChains(ctx context.Context, in *wallet.ListSupportedChainsRequest) (*wallet.ListSupportedChainsResponse, error) {
+	chainList := s.chains.Chains()
+	out := make([]*wallet.ChainInfo, 0, len(chainList))
+	for _, c := range chainList {
+		networks := make([]*wallet.NetworkInfo, 0, len(c.Networks))
+		for _, n := range c.Networks {
+			networks = append(networks, &wallet.NetworkInfo{
+				Name:           n.Name,
+				Aliases:        n.Aliases,
+				ChainId:        n.ChainId,
+				Params:         n.Params,
+				DerivationPath: n.DerivationPath,
+			})
+		}
+		out = append(out, &wallet.ChainInfo{
+			Name:           c.Name,
+			Aliases:        c.Aliases,
+			Curve:          c.Curve,
+			AddressFormats: c.AddressFormats,
+			DerivationPath: c.DerivationPath,
+			Networks:       networks,
+		})
+	}
+	return &wallet.ListSupportedChainsResponse{
+		Code:   strconv.Itoa(200),
+		Msg:    "success request",
+		Chains: out,
 	}, nil
 }
 
+// GetWalletAddress issues a new address for the caller's business id on the
+// requested chain and network. Bitcoin requests may pick the address type,
+// falling back to native segwit. HD requests derive the key from the business
+// seed at the next index and return its derivation path. The key pair is
+// persisted by the shared issuer before the address is returned.
+//
+// Chain and network aliases are resolved through the chain registry;
+// unsupported combinations fail with InvalidArgument. A business id other
+// than the consumer token's fails with PermissionDenied; an empty one
+// defaults to the token's.
 func (s *RpcServer) GetWalletAddress(ctx context.Context, in *wallet.WalletAddressRequest) (*wallet.WalletAddressResponse, error) {
-	addressInfo, err := addresses.CreateAddressFromPrivateKey()
+	businessId, err := requestBusinessId(ctx, in.BusinessId)
+	if err != nil {
+		return nil, err
+	}
+	chain, network, err := s.chains.Resolve(in.Chain, in.Network)
+	if err != nil {
+		return nil, statusError(err, "create address fail")
+	}
+	addressInfo, err := s.issuer.IssueAddress(&issuer.AddressRequest{
+		BusinessId:  businessId,
+		Chain:       chain,
+		Network:     network,
+		AddressType: in.AddressType,
+		HD:          in.Hd,
+	})
+	if err != nil {
+		return nil, statusError(err, "create address fail")
+	}
+	response := &wallet.WalletAddressResponse{
+		Code:           strconv.Itoa(200),
+		Msg:            "success request",
+		Address:        addressInfo.Address,
+		PublicKey:      addressInfo.PublicKey,
+		AddressType:    addressInfo.AddressType,
+		DerivationPath: addressInfo.DerivationPath,
+	}
+	if addressInfo.DerivationIndex != nil {
+		response.DerivationIndex = *addressInfo.DerivationIndex
+	}
+	return response, nil
+}
+
+// BatchCreateAddresses creates count addresses for a business id and streams
+// them back as they are stored, one message per chunk of
+// issuer.BatchChunkSize addresses. Every message carries the number of
+// addresses created so far and the requested total. Generation stops when the
+// client cancels the call; the addresses already streamed stay stored. As for
+// GetWalletAddress, the business id must be the consumer token's.
+func (s *RpcServer) BatchCreateAddresses(in *wallet.BatchCreateAddressesRequest, stream wallet.WalletService_BatchCreateAddressesServer) error {
+	ctx := stream.Context()
+	businessId, err := requestBusinessId(ctx, in.BusinessId)
+	if err != nil {
+		return err
+	}
+	chain, network, err := s.chains.Resolve(in.Chain, in.Network)
+	if err != nil {
+		return statusError(err, "create address fail")
+	}
+	req := &issuer.AddressRequest{
+		BusinessId:  businessId,
+		Chain:       chain,
+		Network:     network,
+		AddressType: in.AddressType,
+	}
+	err = s.issuer.IssueAddresses(ctx, req, int(in.Count), func(chunk []issuer.Address, created int) error {
+		records := make([]*wallet.AddressRecord, 0, len(chunk))
+		for _, address := range chunk {
+			records = append(records, &wallet.AddressRecord{
+				Address:     address.Address,
+				PublicKey:   address.PublicKey,
+				AddressType: address.AddressType,
+			})
+		}
+		return stream.Send(&wallet.BatchCreateAddressesResponse{
+			Code:      strconv.Itoa(200),
+			Msg:       "success request",
+			Addresses: records,
+			Created:   uint32(created),
+			Total:     in.Count,
+		})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Warn("batch create addresses cancelled", "business_id", businessId, "err", err)
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return statusError(err, "create address fail")
+	}
+	return nil
+}
+
+// ListKeys returns one page of the keys issued to the business of the
+// consumer token. A business id other than the token's fails with
+// PermissionDenied; an empty one defaults to the token's.
+//
+// Only the public part of every key is returned, together with the address
+// derived from it; private key material never leaves the database layer.
+// Pages above database.MaxPage and page sizes above database.MaxPageSize fail
+// with InvalidArgument.
+func (s *RpcServer) ListKeys(ctx context.Context, in *wallet.ListKeysRequest) (*wallet.ListKeysResponse, error) {
+	businessId, err := requestBusinessId(ctx, in.BusinessId)
 	if err != nil {
-		fmt.Println("err create address")
-		return &wallet.WalletAddressResponse{
-			Code:      strconv.Itoa(400),
-			Msg:       "create address fail",
-			Address:   "",
-			PublicKey: "",
-		}, nil
+		return nil, err
+	}
+	if err := database.ValidatePagination(in.Page, in.PageSize); err != nil {
+		return nil, statusError(err, "query keys fail")
+	}
+	page, pageSize := database.NormalizePagination(in.Page, in.PageSize)
+	keyList, total, err := s.db.KeysView.QueryKeysByBusId(businessId, page, pageSize)
+	if err != nil {
+		return nil, statusError(err, "query keys fail")
+	}
+	keys := make([]*wallet.KeyInfo, 0, len(keyList))
+	for _, k := range keyList {
+		address, err := issuer.KeyAddress(k)
+		if err != nil {
+			return nil, statusError(err, "derive address fail")
+		}
+		keys = append(keys, &wallet.KeyInfo{
+			Guid:           k.GUID.String(),
+			BusinessId:     k.BusinessId,
+			PublicKey:      k.PublicKey,
+			Address:        address,
+			Timestamp:      k.Timestamp,
+			Chain:          k.Chain,
+			Network:        k.Network,
+			AddressType:    k.AddressType,
+			DerivationPath: k.DerivationPath,
+		})
 	}
-	return &wallet.WalletAddressResponse{
+	return &wallet.ListKeysResponse{
+		Code:     strconv.Itoa(200),
+		Msg:      "success request",
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+		Keys:     keys,
+	}, nil
+}
+
+// SignTransaction signs an unsigned Ethereum transaction with the stored key
+// for the requested public key or address and returns the raw signed
+// transaction together with its hash. Keys of other businesses than the
+// consumer token's are rejected with PermissionDenied.
+func (s *RpcServer) SignTransaction(ctx context.Context, in *wallet.SignTransactionRequest) (*wallet.SignTransactionResponse, error) {
+	businessId, err := callerBusinessId(ctx)
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	if in.TxType > math.MaxUint8 {
+		return nil, statusError(fmt.Errorf("%w: unsupported transaction type %d", signer.ErrInvalidRequest, in.TxType), "sign fail")
+	}
+	accessList := make([]signer.AccessTuple, 0, len(in.AccessList))
+	for _, tuple := range in.AccessList {
+		accessList = append(accessList, signer.AccessTuple{
+			Address:     tuple.Address,
+			StorageKeys: tuple.StorageKeys,
+		})
+	}
+	signedTx, err := s.signer.SignTransaction(&signer.TransactionRequest{
+		BusinessId: businessId,
+		PublicKey:  in.PublicKey,
+		Address:    in.Address,
+		ChainId:    in.ChainId,
+		Type:       uint8(in.TxType),
+		Nonce:      in.Nonce,
+		To:         in.To,
+		Value:      in.Value,
+		Gas:        in.GasLimit,
+		GasPrice:   in.GasPrice,
+		GasTipCap:  in.MaxPriorityFeePerGas,
+		GasFeeCap:  in.MaxFeePerGas,
+		Data:       in.Data,
+		AccessList: accessList,
+	})
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	return &wallet.SignTransactionResponse{
+		Code:     strconv.Itoa(200),
+		Msg:      "success request",
+		SignedTx: signedTx.RawTx,
+		TxHash:   signedTx.TxHash,
+	}, nil
+}
+
+// SignMessage signs an EIP-191 personal_sign message with the stored key for
+// the requested public key or address, which must belong to the business of
+// the consumer token.
+func (s *RpcServer) SignMessage(ctx context.Context, in *wallet.SignMessageRequest) (*wallet.SignatureResponse, error) {
+	businessId, err := callerBusinessId(ctx)
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	sig, err := s.signer.SignMessage(&signer.MessageRequest{
+		BusinessId: businessId,
+		PublicKey:  in.PublicKey,
+		Address:    in.Address,
+		Message:    in.Message,
+	})
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	return &wallet.SignatureResponse{
 		Code:      strconv.Itoa(200),
 		Msg:       "success request",
-		Address:   addressInfo.Address,
-		PublicKey: addressInfo.PublicKey,
+		Signature: sig.Signature,
+		Hash:      sig.Hash,
 	}, nil
 }
+
+// SignTypedData signs an EIP-712 typed data document with the stored key for
+// the requested public key or address, which must belong to the business of
+// the consumer token, after checking the domain's chain id against the
+// requested network.
+func (s *RpcServer) SignTypedData(ctx context.Context, in *wallet.SignTypedDataRequest) (*wallet.SignatureResponse, error) {
+	businessId, err := callerBusinessId(ctx)
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	network, err := s.chains.Network(chains.Ethereum, in.Network)
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	sig, err := s.signer.SignTypedData(&signer.TypedDataRequest{
+		BusinessId: businessId,
+		PublicKey:  in.PublicKey,
+		Address:    in.Address,
+		Network:    network.Name,
+		ChainId:    network.ChainId,
+		TypedData:  []byte(in.TypedData),
+	})
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	return &wallet.SignatureResponse{
+		Code:      strconv.Itoa(200),
+		Msg:       "success request",
+		Signature: sig.Signature,
+		Hash:      sig.Hash,
+	}, nil
+}
+
+// SignPsbt signs the P2WPKH and P2TR key path inputs of a base64 PSBT whose
+// keys are stored in the keys table for the business of the consumer token,
+// and reports the inputs it signed and the ones it skipped.
+func (s *RpcServer) SignPsbt(ctx context.Context, in *wallet.SignPsbtRequest) (*wallet.SignPsbtResponse, error) {
+	businessId, err := callerBusinessId(ctx)
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	network, err := s.chains.Network(chains.Bitcoin, in.Network)
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	signed, err := s.signer.SignPsbt(&signer.PsbtRequest{
+		BusinessId: businessId,
+		Network:    network.Name,
+		Params:     network.Params,
+		Psbt:       in.Psbt,
+	})
+	if err != nil {
+		return nil, statusError(err, "sign fail")
+	}
+	return &wallet.SignPsbtResponse{
+		Code:          strconv.Itoa(200),
+		Msg:           "success request",
+		Psbt:          signed.Psbt,
+		SignedInputs:  toPsbtInputs(signed.Signed),
+		SkippedInputs: toPsbtInputs(signed.Skipped),
+	}, nil
+}
+
+func toPsbtInputs(inputs []signer.PsbtInput) []*wallet.PsbtInput {
+	out := make([]*wallet.PsbtInput, 0, len(inputs))
+	for _, input := range inputs {
+		out = append(out, &wallet.PsbtInput{
+			Index:   input.Index,
+			Address: input.Address,
+			Reason:  input.Reason,
+		})
+	}
+	return out
+}
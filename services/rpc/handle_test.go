@@ -0,0 +1,222 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/config"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/protobuf/wallet"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+func TestChainValidation(t *testing.T) {
+	s := &RpcServer{chains: chains.DefaultRegistry()}
+	ctx := auth.NewContext(context.Background(), &auth.Credential{BusinessId: "merchant-1"})
+
+	for _, tt := range []struct {
+		chain, network string
+		support        bool
+	}{
+		{"Bitcoin", "MainNet", true},
+		{"ethereum", "mainnet", true},
+		{"ETH", "sepolia", true},
+		{"Dogecoin", "MainNet", false},
+		{"Bitcoin", "sepolia", false},
+	} {
+		resp, err := s.GetSupportCoins(ctx, &wallet.SupportCoinsRequest{Chain: tt.chain, Network: tt.network})
+		if err != nil || resp.Support != tt.support {
+			t.Errorf("%s/%s: got %v %v, want support %v", tt.chain, tt.network, resp, err, tt.support)
+		}
+	}
+
+	_, err := s.GetWalletAddress(ctx, &wallet.WalletAddressRequest{BusinessId: "merchant-1", Chain: "Dogecoin", Network: "MainNet"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unsupported chain: got %v, want InvalidArgument", err)
+	}
+	_, err = s.SignPsbt(ctx, &wallet.SignPsbtRequest{Network: "sepolia"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unsupported network: got %v, want InvalidArgument", err)
+	}
+}
+
+// newSQLiteServer returns a server backed by an in-memory sqlite database.
+func newSQLiteServer(t *testing.T) *RpcServer {
+	t.Helper()
+	return newSQLiteServerFor(t, nil)
+}
+
+// newSQLiteServerFor returns a server for the chains of registry backed by an
+// in-memory sqlite database.
+func newSQLiteServerFor(t *testing.T, registry *chains.Registry) *RpcServer {
+	t.Helper()
+	db, err := database.NewDB(context.Background(), config.DBConfig{Driver: database.DriverSQLite, Path: database.SQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.PrepareSchema(""); err != nil {
+		t.Fatal(err)
+	}
+	keyCipher, err := envelope.NewCipher(make([]byte, 32), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRpcServer(db, keyCipher, &RpcServerConfig{Chains: registry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// issueToken stores a consumer token with every scope for businessId and
+// returns an incoming context carrying it as metadata.
+func issueToken(t *testing.T, s *RpcServer, businessId string) context.Context {
+	t.Helper()
+	token, _, err := auth.IssueToken(s.db.Tokens, businessId, auth.AllScopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(ConsumerTokenMetadataKey, token))
+}
+
+// callUnary runs a unary handler behind the auth interceptor, as the gRPC
+// server does.
+func callUnary[Req, Resp any](s *RpcServer, ctx context.Context, method string, req Req, handler func(context.Context, Req) (Resp, error)) (Resp, error) {
+	var zero Resp
+	resp, err := s.authUnaryInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		return handler(ctx, req.(Req))
+	})
+	if err != nil {
+		return zero, err
+	}
+	return resp.(Resp), nil
+}
+
+// TestSQLiteService issues, lists and signs with a key end to end on an
+// in-memory sqlite database.
+func TestSQLiteService(t *testing.T) {
+	s := newSQLiteServer(t)
+	ctx := issueToken(t, s, "merchant-1")
+
+	for _, hd := range []bool{false, true, true} {
+		_, err := callUnary(s, ctx, wallet.WalletService_GetWalletAddress_FullMethodName,
+			&wallet.WalletAddressRequest{BusinessId: "merchant-1", Chain: "ETH", Network: "MainNet", Hd: hd}, s.GetWalletAddress)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys, err := callUnary(s, ctx, wallet.WalletService_ListKeys_FullMethodName,
+		&wallet.ListKeysRequest{BusinessId: "merchant-1"}, s.ListKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Total != 3 || len(keys.Keys) != 3 {
+		t.Fatalf("got %d of %d keys, want 3", len(keys.Keys), keys.Total)
+	}
+	_, err = callUnary(s, ctx, wallet.WalletService_ListKeys_FullMethodName,
+		&wallet.ListKeysRequest{BusinessId: "merchant-1", Page: database.MaxPage + 1}, s.ListKeys)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("page above MaxPage: got %v, want InvalidArgument", err)
+	}
+	_, err = callUnary(s, ctx, wallet.WalletService_SignMessage_FullMethodName,
+		&wallet.SignMessageRequest{Address: keys.Keys[2].Address, Message: "hello"}, s.SignMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSignOtherBusinessKey checks that a consumer token of one business
+// cannot sign with the keys of another.
+func TestSignOtherBusinessKey(t *testing.T) {
+	s := newSQLiteServer(t)
+	ctxA := issueToken(t, s, "merchant-a")
+	ctxB := issueToken(t, s, "merchant-b")
+
+	address, err := callUnary(s, ctxA, wallet.WalletService_GetWalletAddress_FullMethodName,
+		&wallet.WalletAddressRequest{BusinessId: "merchant-a", Chain: "Ethereum", Network: "MainNet"}, s.GetWalletAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = callUnary(s, ctxB, wallet.WalletService_SignMessage_FullMethodName,
+		&wallet.SignMessageRequest{Address: address.Address, Message: "hello"}, s.SignMessage)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("sign message: got %v, want PermissionDenied", err)
+	}
+	_, err = callUnary(s, ctxB, wallet.WalletService_SignTransaction_FullMethodName,
+		&wallet.SignTransactionRequest{PublicKey: address.PublicKey, ChainId: "1", GasPrice: "1"}, s.SignTransaction)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("sign transaction: got %v, want PermissionDenied", err)
+	}
+	_, err = callUnary(s, ctxA, wallet.WalletService_SignMessage_FullMethodName,
+		&wallet.SignMessageRequest{Address: address.Address, Message: "hello"}, s.SignMessage)
+	if err != nil {
+		t.Errorf("owner: %v", err)
+	}
+}
+
+// TestConfiguredChains checks that issuance and signing follow the chain
+// config rather than the built-in chains.
+func TestConfiguredChains(t *testing.T) {
+	registry, err := chains.FromConfig([]config.ChainConfig{
+		{Name: chains.Bitcoin, AddressFormats: []string{"p2wpkh"}, Networks: []config.NetworkConfig{{Name: chains.MainNet}}},
+		{Name: chains.Ethereum, Networks: []config.NetworkConfig{{Name: chains.TestNet, Aliases: []string{"holesky"}, ChainId: 17000}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSQLiteServerFor(t, registry)
+	ctx := issueToken(t, s, "merchant-1")
+
+	for _, tt := range []struct {
+		name string
+		req  *wallet.WalletAddressRequest
+		code codes.Code
+	}{
+		{"configured format", &wallet.WalletAddressRequest{Chain: "Bitcoin", Network: "MainNet", AddressType: "p2wpkh"}, codes.OK},
+		{"unlisted format", &wallet.WalletAddressRequest{Chain: "Bitcoin", Network: "MainNet", AddressType: "p2tr"}, codes.InvalidArgument},
+		{"unlisted network", &wallet.WalletAddressRequest{Chain: "Ethereum", Network: "MainNet"}, codes.InvalidArgument},
+	} {
+		_, err := callUnary(s, ctx, wallet.WalletService_GetWalletAddress_FullMethodName, tt.req, s.GetWalletAddress)
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.code)
+		}
+	}
+
+	address, err := callUnary(s, ctx, wallet.WalletService_GetWalletAddress_FullMethodName,
+		&wallet.WalletAddressRequest{Chain: "Ethereum", Network: "holesky"}, s.GetWalletAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		chainId int
+		code    codes.Code
+	}{
+		{17000, codes.OK},
+		{11155111, codes.InvalidArgument},
+	} {
+		_, err := callUnary(s, ctx, wallet.WalletService_SignTypedData_FullMethodName,
+			&wallet.SignTypedDataRequest{Address: address.Address, Network: "holesky", TypedData: fmt.Sprintf(testTypedData, tt.chainId)}, s.SignTypedData)
+		if status.Code(err) != tt.code {
+			t.Errorf("chain id %d: got %v, want %s", tt.chainId, err, tt.code)
+		}
+	}
+}
+
+const testTypedData = `{
+  "types": {
+    "EIP712Domain": [{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}],
+    "Mail": [{"name": "contents", "type": "string"}]
+  },
+  "primaryType": "Mail",
+  "domain": {"name": "Ether Mail", "chainId": %d},
+  "message": {"contents": "Hello, Bob!"}
+}`
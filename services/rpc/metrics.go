@@ -0,0 +1,28 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+)
+
+// metricsUnaryInterceptor records the count and latency of every unary call,
+// including calls rejected by the interceptors that run after it.
+func metricsUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	metrics.RecordGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+	return resp, err
+}
+
+// metricsStreamInterceptor records the count and duration of every streaming call.
+func metricsStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	metrics.RecordGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+	return err
+}
@@ -2,12 +2,21 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/qiaopengjun5162/go-rpc-service/database"
-	"github.com/qiaopengjun5162/go-rpc-service/protobuf/wallet"
 	"net"
 	"sync/atomic"
 
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/common/httputil"
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/protobuf/wallet"
+	"github.com/qiaopengjun5162/go-rpc-service/services/auth"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+	"github.com/qiaopengjun5162/go-rpc-service/services/issuer"
+	"github.com/qiaopengjun5162/go-rpc-service/services/signer"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
@@ -19,57 +28,160 @@ const MaxRecvMessageSize = 1024 * 1024 * 300
 type RpcServerConfig struct {
 	GrpcHostname string
 	GrpcPort     int
+	MetricsHost  string
+	MetricsPort  int
+	AddressPool  issuer.PoolConfig
+	// Chains is the chain registry requests are validated against. The
+	// default registry is used when it is nil.
+	Chains *chains.Registry
 }
 
 type RpcServer struct {
 	*RpcServerConfig
-	db *database.DB
+	db     *database.DB
+	auth   *auth.Authenticator
+	chains *chains.Registry
+	issuer *issuer.Issuer
+	signer *signer.Signer
+	gs     *grpc.Server
+
+	metricsServer *httputil.HTTPServer
 
 	wallet.UnimplementedWalletServiceServer
 	stopped atomic.Bool
 }
 
+// Stop gracefully stops the gRPC server, the address pool workers and the
+// metrics server and closes the database connection.
+//
+// If the context expires before the in-flight calls finish, the server is
+// stopped forcefully.
+//
+// Parameters:
+//   - ctx: A context.Context that controls the shutdown timeout.
+//
+// Returns:
+//   - error: An error if closing the database fails, or nil if successful.
 func (s *RpcServer) Stop(ctx context.Context) error {
+	var result error
+	if s.gs != nil {
+		done := make(chan struct{})
+		go func() {
+			s.gs.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			s.gs.Stop()
+		}
+	}
+	if s.issuer != nil {
+		s.issuer.Stop()
+	}
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Stop(ctx); err != nil {
+			result = errors.Join(result, fmt.Errorf("failed to stop metrics server: %w", err))
+		}
+	}
+	if s.db != nil {
+		if err := s.db.Close(); err != nil {
+			result = errors.Join(result, fmt.Errorf("failed to close DB: %w", err))
+		}
+	}
 	s.stopped.Store(true)
-	return nil
+	log.Info("rpc service shutdown complete")
+	return result
 }
 
 func (s *RpcServer) Stopped() bool {
 	return s.stopped.Load()
 }
 
-func NewRpcServer(db *database.DB, config *RpcServerConfig) (*RpcServer, error) {
+func NewRpcServer(db *database.DB, cipher *envelope.Cipher, config *RpcServerConfig) (*RpcServer, error) {
+	registry := config.Chains
+	if registry == nil {
+		registry = chains.DefaultRegistry()
+	}
 	return &RpcServer{
 		RpcServerConfig: config,
 		db:              db,
+		auth:            auth.NewAuthenticator(db.Tokens),
+		chains:          registry,
+		issuer:          issuer.NewIssuer(db, cipher, registry, config.AddressPool),
+		signer:          signer.NewSigner(db.KeysView, db.Keys, cipher),
 	}, nil
 }
 
-func (s *RpcServer) Start(ctx context.Context) error {
-	go func(s *RpcServer) {
-		addr := fmt.Sprintf("%s:%d", s.GrpcHostname, s.GrpcPort)
-		log.Info("start rpc services", "addr", addr)
-		listener, err := net.Listen("tcp", addr)
+// Start opens the tcp listener and serves the wallet service in the background,
+// starts the metrics server and starts refilling the address pool.
+//
+// The listener is created synchronously so that a bad host or a port that is
+// already in use fails the lifecycle instead of being logged and ignored. The
+// lifecycle does not call Stop after a failed start, so Start stops the
+// metrics server and closes the database itself when it fails.
+//
+// Parameters:
+//   - ctx: A context.Context that controls the start timeout.
+//
+// Returns:
+//   - error: An error if a listener cannot be created, or nil if successful.
+func (s *RpcServer) Start(ctx context.Context) (err error) {
+	defer func() {
 		if err != nil {
-			log.Error("Could not start tcp listener. ")
+			err = errors.Join(err, s.Stop(ctx))
 		}
+	}()
+	if err := s.startMetricsServer(); err != nil {
+		return err
+	}
+	addr := fmt.Sprintf("%s:%d", s.GrpcHostname, s.GrpcPort)
+	log.Info("start rpc services", "addr", addr)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Error("Could not start tcp listener. ", "err", err)
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
 
-		opt := grpc.MaxRecvMsgSize(MaxRecvMessageSize)
+	opt := grpc.MaxRecvMsgSize(MaxRecvMessageSize)
 
-		gs := grpc.NewServer(
-			opt,
-			grpc.ChainUnaryInterceptor(
-				nil,
-			),
-		)
-		reflection.Register(gs)
+	gs := grpc.NewServer(
+		opt,
+		grpc.ChainUnaryInterceptor(
+			metricsUnaryInterceptor,
+			s.authUnaryInterceptor,
+		),
+		grpc.ChainStreamInterceptor(
+			metricsStreamInterceptor,
+			s.authStreamInterceptor,
+		),
+	)
+	reflection.Register(gs)
 
-		wallet.RegisterWalletServiceServer(gs, s)
+	wallet.RegisterWalletServiceServer(gs, s)
+	s.gs = gs
+	s.issuer.Start()
 
+	go func(s *RpcServer) {
 		log.Info("Grpc info", "port", s.GrpcPort, "address", listener.Addr())
 		if err := gs.Serve(listener); err != nil {
-			log.Error("Could not GRPC services")
+			log.Error("Could not GRPC services", "err", err)
 		}
 	}(s)
 	return nil
 }
+
+// startMetricsServer exports the database pool stats and serves the metrics
+// registry on the configured metrics host and port.
+func (s *RpcServer) startMetricsServer() error {
+	if err := s.db.RegisterMetrics(); err != nil {
+		return err
+	}
+	srv, err := metrics.StartServer(s.MetricsHost, s.MetricsPort)
+	if err != nil {
+		return err
+	}
+	log.Info("started metrics server", "addr", srv.Addr())
+	s.metricsServer = srv
+	return nil
+}
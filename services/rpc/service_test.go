@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+// TestStartFailure starts the server on a port that is already taken and
+// checks that the failed start releases the metrics server and the database.
+func TestStartFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	s := newSQLiteServer(t)
+	s.GrpcHostname = "127.0.0.1"
+	s.GrpcPort = listener.Addr().(*net.TCPAddr).Port
+	s.MetricsHost = "127.0.0.1"
+	if err := s.Start(context.Background()); err == nil {
+		t.Fatal("started on a port that is in use")
+	}
+	if !s.Stopped() {
+		t.Fatal("server not stopped after a failed start")
+	}
+	if _, err := s.db.Keys.QueryKeyByAddress("0x0"); err == nil {
+		t.Fatal("database still open after a failed start")
+	}
+}
@@ -0,0 +1,134 @@
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+// MessageRequest describes an EIP-191 personal_sign request. A 0x prefixed
+// hex Message is signed as raw bytes, anything else as UTF-8 text.
+type MessageRequest struct {
+	// BusinessId is the business of the caller; only its keys can sign.
+	BusinessId string
+	PublicKey  string
+	Address    string
+	Message    string
+}
+
+// TypedDataRequest describes an EIP-712 signing request. TypedData is the
+// JSON document accepted by eth_signTypedData_v4.
+type TypedDataRequest struct {
+	// BusinessId is the business of the caller; only its keys can sign.
+	BusinessId string
+	PublicKey  string
+	Address    string
+	Network    string
+	// ChainId is the chain id of Network in the chain registry, which the
+	// domain's chain id must match.
+	ChainId   uint64
+	TypedData []byte
+}
+
+// Signature is a 65 byte r||s||v signature, with v set to 27 or 28, and the
+// hash that was signed. Both are 0x prefixed hex strings.
+type Signature struct {
+	Signature string
+	Hash      string
+}
+
+// SignMessage signs a message following EIP-191 personal_sign with the stored
+// key for the requested public key or address.
+//
+// Parameters:
+//   - req: A pointer to a MessageRequest holding the message and the key to sign it with.
+//
+// Returns:
+//   - A pointer to a Signature holding the signature and the signed hash.
+//   - An error wrapping ErrInvalidRequest for malformed requests, ErrKeyNotFound
+//     if the key is unknown, ErrKeyNotOwned if it belongs to another business,
+//     ErrKeyWrongChain if it is not an Ethereum key,
+//     or the underlying error otherwise.
+func (s *Signer) SignMessage(req *MessageRequest) (sig *Signature, err error) {
+	defer func() { metrics.RecordSigning("message", err) }()
+	message := []byte(req.Message)
+	if strings.HasPrefix(req.Message, "0x") {
+		decoded, err := hexutil.Decode(req.Message)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid hex message: %v", ErrInvalidRequest, err)
+		}
+		message = decoded
+	}
+	return s.signHash(req.BusinessId, req.PublicKey, req.Address, accounts.TextHash(message))
+}
+
+// SignTypedData signs an EIP-712 typed data document with the stored key for
+// the requested public key or address.
+//
+// The chain id of the document's domain must match req.ChainId, the chain id
+// the registry configures for the requested network, so a signature produced
+// for one network cannot be replayed on another.
+//
+// Parameters:
+//   - req: A pointer to a TypedDataRequest holding the typed data, the network
+//     and the key to sign it with.
+//
+// Returns:
+//   - A pointer to a Signature holding the signature and the EIP-712 hash.
+//   - An error wrapping ErrInvalidRequest for malformed requests, ErrKeyNotFound
+//     if the key is unknown, ErrKeyNotOwned if it belongs to another business,
+//     ErrKeyWrongChain if it is not an Ethereum key, or the underlying error
+//     otherwise.
+func (s *Signer) SignTypedData(req *TypedDataRequest) (sig *Signature, err error) {
+	defer func() { metrics.RecordSigning("typed_data", err) }()
+	if req.ChainId == 0 {
+		return nil, fmt.Errorf("%w: no chain id for network %q", ErrInvalidRequest, req.Network)
+	}
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal(req.TypedData, &typedData); err != nil {
+		return nil, fmt.Errorf("%w: invalid typed data: %v", ErrInvalidRequest, err)
+	}
+	if typedData.Domain.ChainId == nil {
+		return nil, fmt.Errorf("%w: typed data domain has no chainId", ErrInvalidRequest)
+	}
+	domainChainId := (*big.Int)(typedData.Domain.ChainId)
+	if domainChainId.Cmp(new(big.Int).SetUint64(req.ChainId)) != 0 {
+		return nil, fmt.Errorf("%w: domain chainId %s does not match network %s", ErrInvalidRequest, domainChainId, req.Network)
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return s.signHash(req.BusinessId, req.PublicKey, req.Address, hash)
+}
+
+// signHash signs a 32 byte hash with the stored key and converts the recovery
+// id into the 27/28 form expected by Ethereum tooling.
+func (s *Signer) signHash(businessId, publicKey, address string, hash []byte) (*Signature, error) {
+	key, err := s.lookupKey(businessId, chains.Ethereum, "", publicKey, address)
+	if err != nil {
+		return nil, err
+	}
+	prvKey, err := s.privateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(hash, prvKey)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return &Signature{
+		Signature: hexutil.Encode(sig),
+		Hash:      hexutil.Encode(hash),
+	}, nil
+}
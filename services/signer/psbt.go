@@ -0,0 +1,198 @@
+package signer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+	"github.com/qiaopengjun5162/go-rpc-service/services/addresses"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+// PsbtRequest describes a BIP-174 PSBT to be signed on a Bitcoin network.
+type PsbtRequest struct {
+	// BusinessId is the business of the caller; only inputs spending its keys
+	// are signed.
+	BusinessId string
+	Network    string
+	// Params names the chain parameters of Network in the chain registry,
+	// which the addresses of the spent outputs are decoded with.
+	Params string
+	Psbt   string
+}
+
+// PsbtInput reports what happened to one input of a PSBT. Reason is only set
+// for skipped inputs.
+type PsbtInput struct {
+	Index   uint32
+	Address string
+	Reason  string
+}
+
+// SignedPsbt is the updated base64 PSBT together with the inputs that were
+// signed and the inputs that were left untouched.
+type SignedPsbt struct {
+	Psbt    string
+	Signed  []PsbtInput
+	Skipped []PsbtInput
+}
+
+// SignPsbt signs every input of a base64 encoded PSBT whose key is stored in
+// the keys table.
+//
+// Only P2WPKH inputs and P2TR key path inputs are signed; the key is found by
+// the address of the spent output and must belong to the caller's business. P2WPKH signatures are added as partial
+// signatures, P2TR signatures as the taproot key spend signature. Inputs are
+// never finalized so that the PSBT can still be combined with signatures from
+// other signers. Inputs that cannot be signed are reported with a reason.
+//
+// Parameters:
+//   - req: A pointer to a PsbtRequest holding the PSBT and its network.
+//
+// Returns:
+//   - A pointer to a SignedPsbt holding the updated PSBT and the per input outcome.
+//   - An error wrapping ErrInvalidRequest if the PSBT or network is invalid,
+//     or the underlying error if a key cannot be loaded or used.
+func (s *Signer) SignPsbt(req *PsbtRequest) (signed *SignedPsbt, err error) {
+	defer func() { metrics.RecordSigning("psbt", err) }()
+	params, err := addresses.BitcoinNetParams(req.Params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: network %q: %v", ErrInvalidRequest, req.Network, err)
+	}
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader([]byte(req.Psbt)), true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid psbt: %v", ErrInvalidRequest, err)
+	}
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid psbt: %v", ErrInvalidRequest, err)
+	}
+
+	tx := packet.UnsignedTx
+	prevOuts := make(map[wire.OutPoint]*wire.TxOut, len(tx.TxIn))
+	allPrevOutsKnown := true
+	for i, txIn := range tx.TxIn {
+		prevOut := inputPrevOut(&packet.Inputs[i], txIn.PreviousOutPoint)
+		if prevOut == nil {
+			allPrevOutsKnown = false
+			prevOut = &wire.TxOut{}
+		}
+		prevOuts[txIn.PreviousOutPoint] = prevOut
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, txscript.NewMultiPrevOutFetcher(prevOuts))
+
+	result := &SignedPsbt{}
+	for i, txIn := range tx.TxIn {
+		input := &packet.Inputs[i]
+		prevOut := inputPrevOut(input, txIn.PreviousOutPoint)
+		skip := func(address, reason string) {
+			result.Skipped = append(result.Skipped, PsbtInput{Index: uint32(i), Address: address, Reason: reason})
+		}
+		if prevOut == nil {
+			skip("", "missing utxo")
+			continue
+		}
+		if len(input.FinalScriptSig) != 0 || len(input.FinalScriptWitness) != 0 {
+			skip("", "already finalized")
+			continue
+		}
+		class, addrs, _, err := txscript.ExtractPkScriptAddrs(prevOut.PkScript, params)
+		if err != nil || len(addrs) != 1 {
+			skip("", "unrecognized output script")
+			continue
+		}
+		address := addrs[0].EncodeAddress()
+		if class != txscript.WitnessV0PubKeyHashTy && class != txscript.WitnessV1TaprootTy {
+			skip(address, fmt.Sprintf("unsupported script type %s", class))
+			continue
+		}
+		if class == txscript.WitnessV1TaprootTy && !allPrevOutsKnown {
+			skip(address, "taproot signing requires the utxo of every input")
+			continue
+		}
+		key, err := s.lookupKey(req.BusinessId, chains.Bitcoin, req.Network, "", address)
+		if errors.Is(err, ErrKeyNotFound) {
+			skip(address, "key not found")
+			continue
+		}
+		if errors.Is(err, ErrKeyNotOwned) {
+			skip(address, "key belongs to another business")
+			continue
+		}
+		if errors.Is(err, ErrKeyWrongChain) {
+			skip(address, "key was issued for another chain or network")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ecdsaKey, err := s.privateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		prvKey, _ := btcec.PrivKeyFromBytes(crypto.FromECDSA(ecdsaKey))
+
+		switch class {
+		case txscript.WitnessV0PubKeyHashTy:
+			hashType := input.SighashType
+			if hashType == 0 {
+				hashType = txscript.SigHashAll
+			}
+			sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, i, prevOut.Value, prevOut.PkScript, hashType, prvKey)
+			if err != nil {
+				return nil, err
+			}
+			outcome, err := updater.Sign(i, sig, prvKey.PubKey().SerializeCompressed(), nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			if outcome != psbt.SignSuccesful {
+				skip(address, "psbt rejected the signature")
+				continue
+			}
+		case txscript.WitnessV1TaprootTy:
+			if len(input.TaprootMerkleRoot) != 0 {
+				skip(address, "taproot script tree is not supported")
+				continue
+			}
+			sig, err := txscript.RawTxInTaprootSignature(tx, sigHashes, i, prevOut.Value, prevOut.PkScript, nil, input.SighashType, prvKey)
+			if err != nil {
+				skip(address, err.Error())
+				continue
+			}
+			input.TaprootKeySpendSig = sig
+			if len(input.TaprootInternalKey) == 0 {
+				input.TaprootInternalKey = schnorr.SerializePubKey(prvKey.PubKey())
+			}
+		}
+		result.Signed = append(result.Signed, PsbtInput{Index: uint32(i), Address: address})
+	}
+
+	encoded, err := packet.B64Encode()
+	if err != nil {
+		return nil, err
+	}
+	result.Psbt = encoded
+	return result, nil
+}
+
+// inputPrevOut returns the output spent by a PSBT input, preferring the
+// witness utxo and falling back to the full previous transaction.
+func inputPrevOut(input *psbt.PInput, outPoint wire.OutPoint) *wire.TxOut {
+	if input.WitnessUtxo != nil {
+		return input.WitnessUtxo
+	}
+	if input.NonWitnessUtxo != nil && input.NonWitnessUtxo.TxHash() == outPoint.Hash &&
+		int(outPoint.Index) < len(input.NonWitnessUtxo.TxOut) {
+		return input.NonWitnessUtxo.TxOut[outPoint.Index]
+	}
+	return nil
+}
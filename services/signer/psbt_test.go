@@ -0,0 +1,171 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/qiaopengjun5162/go-rpc-service/services/addresses"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+// newBitcoinTestKey stores a new TestNet key for the given address type and
+// returns the output script paying to its address.
+func newBitcoinTestKey(t *testing.T, s *Signer, addressType string) []byte {
+	return newBitcoinNetworkKey(t, s, chains.TestNet, addressType)
+}
+
+// newBitcoinNetworkKey stores a new key issued on network, with a testnet3
+// address, and returns the output script paying to its address.
+func newBitcoinNetworkKey(t *testing.T, s *Signer, network, addressType string) []byte {
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := hex.EncodeToString(crypto.CompressPubkey(&prvKey.PublicKey))
+	address, err := addresses.PublicKeyToBitcoinAddress(publicKey, addressType, "TestNet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestKey(t, s, prvKey, chains.Bitcoin, network, publicKey, address)
+	params, _ := addresses.BitcoinNetParams("TestNet")
+	decoded, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkScript
+}
+
+func TestSignPsbt(t *testing.T) {
+	s, _ := newTestSigner(t)
+	p2wpkhScript := newBitcoinTestKey(t, s, addresses.AddressTypeP2WPKH)
+	p2trScript := newBitcoinTestKey(t, s, addresses.AddressTypeP2TR)
+	p2pkhScript := newBitcoinTestKey(t, s, addresses.AddressTypeP2PKH)
+	foreignScript := append([]byte{txscript.OP_0, txscript.OP_DATA_20}, bytes.Repeat([]byte{0x01}, 20)...)
+
+	prevOuts := []*wire.TxOut{
+		wire.NewTxOut(10_000, p2wpkhScript),
+		wire.NewTxOut(20_000, p2trScript),
+		wire.NewTxOut(30_000, foreignScript),
+		wire.NewTxOut(40_000, p2pkhScript),
+	}
+	outPoints := make([]*wire.OutPoint, 0, len(prevOuts))
+	sequences := make([]uint32, 0, len(prevOuts))
+	for i := range prevOuts {
+		outPoints = append(outPoints, wire.NewOutPoint(&chainhash.Hash{byte(i + 1)}, uint32(i)))
+		sequences = append(sequences, wire.MaxTxInSequenceNum)
+	}
+	packet, err := psbt.New(outPoints, []*wire.TxOut{wire.NewTxOut(90_000, foreignScript)}, 2, 0, sequences)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, prevOut := range prevOuts {
+		packet.Inputs[i].WitnessUtxo = prevOut
+	}
+	encoded, err := packet.B64Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := s.SignPsbt(&PsbtRequest{BusinessId: "merchant-2", Network: "TestNet", Params: "testnet3", Psbt: encoded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Signed) != 0 {
+		t.Fatalf("signed inputs of another business %+v", other.Signed)
+	}
+
+	signed, err := s.SignPsbt(&PsbtRequest{BusinessId: testBusinessId, Network: "TestNet", Params: "testnet3", Psbt: encoded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signed.Signed) != 2 || signed.Signed[0].Index != 0 || signed.Signed[1].Index != 1 {
+		t.Fatalf("unexpected signed inputs %+v", signed.Signed)
+	}
+	if len(signed.Skipped) != 2 || signed.Skipped[0].Index != 2 || signed.Skipped[1].Index != 3 {
+		t.Fatalf("unexpected skipped inputs %+v", signed.Skipped)
+	}
+
+	result, err := psbt.NewFromRawBytes(bytes.NewReader([]byte(signed.Psbt)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, prevOut := range prevOuts {
+		fetcher.AddPrevOut(result.UnsignedTx.TxIn[i].PreviousOutPoint, prevOut)
+	}
+	sigHashes := txscript.NewTxSigHashes(result.UnsignedTx, fetcher)
+	for _, in := range signed.Signed {
+		if err := psbt.Finalize(result, int(in.Index)); err != nil {
+			t.Fatalf("finalize input %d: %v", in.Index, err)
+		}
+		witness := readWitness(t, result.Inputs[in.Index].FinalScriptWitness)
+		tx := result.UnsignedTx.Copy()
+		tx.TxIn[in.Index].Witness = witness
+		prevOut := prevOuts[in.Index]
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, int(in.Index), txscript.StandardVerifyFlags,
+			nil, sigHashes, prevOut.Value, fetcher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("input %d does not verify: %v", in.Index, err)
+		}
+	}
+
+	if _, err := s.SignPsbt(&PsbtRequest{Network: "TestNet", Params: "testnet3", Psbt: "not a psbt"}); !IsInvalidRequest(err) {
+		t.Fatalf("invalid psbt: got %v", err)
+	}
+}
+
+// TestSignPsbtWrongNetwork skips an input whose key was issued on a network
+// that shares the testnet3 address encoding, such as signet.
+func TestSignPsbtWrongNetwork(t *testing.T) {
+	s, _ := newTestSigner(t)
+	script := newBitcoinNetworkKey(t, s, "Signet", addresses.AddressTypeP2WPKH)
+	packet, err := psbt.New([]*wire.OutPoint{wire.NewOutPoint(&chainhash.Hash{1}, 0)}, []*wire.TxOut{wire.NewTxOut(9_000, script)}, 2, 0, []uint32{wire.MaxTxInSequenceNum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet.Inputs[0].WitnessUtxo = wire.NewTxOut(10_000, script)
+	encoded, err := packet.B64Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := s.SignPsbt(&PsbtRequest{BusinessId: testBusinessId, Network: chains.TestNet, Params: "testnet3", Psbt: encoded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signed.Signed) != 0 || len(signed.Skipped) != 1 || signed.Skipped[0].Reason != "key was issued for another chain or network" {
+		t.Fatalf("unexpected outcome %+v", signed)
+	}
+}
+
+// readWitness decodes a serialized PSBT final script witness.
+func readWitness(t *testing.T, serialized []byte) wire.TxWitness {
+	r := bytes.NewReader(serialized)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witness := make(wire.TxWitness, 0, count)
+	for i := uint64(0); i < count; i++ {
+		item, err := wire.ReadVarBytes(r, 0, txscript.MaxScriptSize, "witness item")
+		if err != nil {
+			t.Fatal(err)
+		}
+		witness = append(witness, item)
+	}
+	return witness
+}
@@ -0,0 +1,139 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+var (
+	ErrKeyNotFound    = errors.New("key not found")
+	ErrKeyNotOwned    = errors.New("key belongs to another business")
+	ErrKeyWrongChain  = errors.New("key was issued for another chain or network")
+	ErrKeyRequired    = errors.New("public_key or address is required")
+	ErrInvalidRequest = errors.New("invalid signing request")
+)
+
+// Signer signs payloads with keys stored in the keys table.
+//
+// It is the only place where private keys are decrypted: keys are looked up by
+// public key or address, checked to belong to the caller's business, opened
+// with the envelope cipher, used once and then dropped.
+//
+// Keys are read from keysView, which may be a lagging read replica. A key the
+// replica does not have, still holds as pooled or does not show as owned by
+// the caller is read again from keys on the primary, so a key can be used for
+// signing as soon as it is issued.
+type Signer struct {
+	keysView database.KeysView
+	keys     database.KeysView
+	cipher   *envelope.Cipher
+}
+
+func NewSigner(keysView, keys database.KeysView, cipher *envelope.Cipher) *Signer {
+	return &Signer{
+		keysView: keysView,
+		keys:     keys,
+		cipher:   cipher,
+	}
+}
+
+// lookupKey finds the stored key for the given public key or address and
+// checks that it was issued to businessId for chain, and for network unless
+// it is empty. Pooled keys, which are not assigned to any business yet, are
+// never returned. The public key takes precedence when both are set.
+// Ethereum addresses are stored in their checksummed form, so they are
+// normalized before the lookup.
+func (s *Signer) lookupKey(businessId, chain, network, publicKey, address string) (*database.Keys, error) {
+	if publicKey == "" && address == "" {
+		return nil, ErrKeyRequired
+	}
+	if address != "" && common.IsHexAddress(address) {
+		address = common.HexToAddress(address).Hex()
+	}
+	key, err := queryKey(s.keysView, publicKey, address)
+	if err != nil {
+		return nil, err
+	}
+	if !ownedBy(key, businessId) && s.keys != nil && s.keys != s.keysView {
+		key, err = queryKey(s.keys, publicKey, address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if key == nil {
+		return nil, ErrKeyNotFound
+	}
+	if !ownedBy(key, businessId) {
+		return nil, ErrKeyNotOwned
+	}
+	keyChain := key.Chain
+	if keyChain == "" {
+		// Keys stored before the chain was recorded are Ethereum keys.
+		keyChain = chains.Ethereum
+	}
+	if keyChain != chain || (network != "" && key.Network != network) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrKeyWrongChain, keyChain, key.Network)
+	}
+	return key, nil
+}
+
+func queryKey(view database.KeysView, publicKey, address string) (*database.Keys, error) {
+	if publicKey != "" {
+		return view.QueryKeyByPublicKey(publicKey)
+	}
+	return view.QueryKeyByAddress(address)
+}
+
+// ownedBy reports whether key is assigned to businessId.
+func ownedBy(key *database.Keys, businessId string) bool {
+	return key != nil && !key.Pooled && key.BusinessId != "" && key.BusinessId == businessId
+}
+
+// privateKey decrypts the private key of a stored row and checks that it
+// matches the public key recorded alongside it, which is uncompressed for
+// Ethereum keys and compressed for Bitcoin keys.
+func (s *Signer) privateKey(key *database.Keys) (*ecdsa.PrivateKey, error) {
+	sealedKey, err := hex.DecodeString(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key %s: %w", key.GUID, err)
+	}
+	rawKey := sealedKey
+	if key.KeyVersion != envelope.PlaintextVersion {
+		dataKey, err := hex.DecodeString(key.DataKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data key %s: %w", key.GUID, err)
+		}
+		rawKey, err = s.cipher.Open(&envelope.Sealed{
+			Ciphertext: sealedKey,
+			DataKey:    dataKey,
+			KeyVersion: key.KeyVersion,
+		}, key.GUID[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key %s: %w", key.GUID, err)
+		}
+	}
+	prvKey, err := crypto.ToECDSA(rawKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key %s: %w", key.GUID, err)
+	}
+	uncompressed := hex.EncodeToString(crypto.FromECDSAPub(&prvKey.PublicKey))
+	compressed := hex.EncodeToString(crypto.CompressPubkey(&prvKey.PublicKey))
+	if key.PublicKey != uncompressed && key.PublicKey != compressed {
+		return nil, fmt.Errorf("private key %s does not match its public key", key.GUID)
+	}
+	return prvKey, nil
+}
+
+// IsInvalidRequest reports whether err was caused by the caller's input.
+func IsInvalidRequest(err error) bool {
+	return errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrKeyRequired) || errors.Is(err, ErrKeyWrongChain)
+}
@@ -0,0 +1,311 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/envelope"
+	"github.com/qiaopengjun5162/go-rpc-service/database"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+type memKeysView struct {
+	keys []database.Keys
+}
+
+func (m *memKeysView) QueryKeysByBusId(string, uint64, uint64) ([]database.Keys, int64, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (m *memKeysView) QueryKeyByPublicKey(publicKey string) (*database.Keys, error) {
+	for i := range m.keys {
+		if m.keys[i].PublicKey == publicKey {
+			return &m.keys[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memKeysView) QueryKeyByAddress(address string) (*database.Keys, error) {
+	for i := range m.keys {
+		if m.keys[i].Address == address {
+			return &m.keys[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// testBusinessId owns the keys added by addTestKey.
+const testBusinessId = "merchant-1"
+
+// newTestSigner returns a signer holding one sealed Ethereum key and the address of that key.
+func newTestSigner(t *testing.T) (*Signer, string) {
+	masterKey := make([]byte, 32)
+	if _, err := rand.Read(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := envelope.NewCipher(masterKey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSigner(&memKeysView{}, nil, cipher)
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(prvKey.PublicKey).Hex()
+	addTestKey(t, s, prvKey, chains.Ethereum, chains.MainNet, hex.EncodeToString(crypto.FromECDSAPub(&prvKey.PublicKey)), address)
+	return s, address
+}
+
+// addTestKey seals a private key the same way the issuer does and stores it
+// in the signer's in-memory keys view.
+func addTestKey(t *testing.T, s *Signer, prvKey *ecdsa.PrivateKey, chain, network, publicKey, address string) {
+	guid := uuid.New()
+	sealed, err := s.cipher.Seal(crypto.FromECDSA(prvKey), guid[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := s.keysView.(*memKeysView)
+	view.keys = append(view.keys, database.Keys{
+		GUID:       guid,
+		BusinessId: testBusinessId,
+		PrivateKey: hex.EncodeToString(sealed.Ciphertext),
+		DataKey:    hex.EncodeToString(sealed.DataKey),
+		KeyVersion: sealed.KeyVersion,
+		PublicKey:  publicKey,
+		Address:    address,
+		Chain:      chain,
+		Network:    network,
+	})
+}
+
+func TestSignTransaction(t *testing.T) {
+	s, address := newTestSigner(t)
+	tests := []TransactionRequest{
+		{Type: types.LegacyTxType, GasPrice: "1000000000"},
+		{Type: types.AccessListTxType, GasPrice: "1000000000", AccessList: []AccessTuple{{
+			Address:     address,
+			StorageKeys: []string{"0x0000000000000000000000000000000000000000000000000000000000000001"},
+		}}},
+		{Type: types.DynamicFeeTxType, GasTipCap: "0x3b9aca00", GasFeeCap: "30000000000"},
+	}
+	for _, req := range tests {
+		req.BusinessId = testBusinessId
+		req.Address = address
+		req.ChainId = "11155111"
+		req.To = address
+		req.Value = "1000"
+		req.Gas = 21000
+		signedTx, err := s.SignTransaction(&req)
+		if err != nil {
+			t.Fatalf("type %d: %v", req.Type, err)
+		}
+		rawTx, err := hexutil.Decode(signedTx.RawTx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(rawTx); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Type() != req.Type || tx.Hash().Hex() != signedTx.TxHash {
+			t.Fatalf("type %d: decoded transaction does not match", req.Type)
+		}
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sender.Hex() != address {
+			t.Fatalf("type %d: got sender %s, want %s", req.Type, sender.Hex(), address)
+		}
+	}
+}
+
+func TestSignTransactionErrors(t *testing.T) {
+	s, address := newTestSigner(t)
+	_, err := s.SignTransaction(&TransactionRequest{BusinessId: testBusinessId, Address: address, GasPrice: "1"})
+	if !IsInvalidRequest(err) {
+		t.Fatalf("missing chain id: got %v", err)
+	}
+	_, err = s.SignTransaction(&TransactionRequest{BusinessId: testBusinessId, Address: address, ChainId: "1", Type: types.DynamicFeeTxType})
+	if !IsInvalidRequest(err) {
+		t.Fatalf("missing fee caps: got %v", err)
+	}
+	_, err = s.SignTransaction(&TransactionRequest{BusinessId: testBusinessId, ChainId: "1", GasPrice: "1"})
+	if !IsInvalidRequest(err) {
+		t.Fatalf("missing key: got %v", err)
+	}
+	_, err = s.SignTransaction(&TransactionRequest{BusinessId: testBusinessId, Address: "0x0000000000000000000000000000000000000001", ChainId: "1", GasPrice: "1"})
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("unknown key: got %v", err)
+	}
+}
+
+// recoverAddress recovers the signer address of a 27/28 style signature.
+func recoverAddress(t *testing.T, sig *Signature) string {
+	sigBytes, err := hexutil.Decode(sig.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hexutil.Decode(sig.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigBytes) != crypto.SignatureLength {
+		t.Fatalf("got %d byte signature", len(sigBytes))
+	}
+	sigBytes[crypto.RecoveryIDOffset] -= 27
+	pubKey, err := crypto.SigToPub(hash, sigBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto.PubkeyToAddress(*pubKey).Hex()
+}
+
+func TestSignMessage(t *testing.T) {
+	s, address := newTestSigner(t)
+	sig, err := s.SignMessage(&MessageRequest{BusinessId: testBusinessId, Address: address, Message: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Hash != hexutil.Encode(accounts.TextHash([]byte("hello"))) {
+		t.Fatalf("unexpected hash %s", sig.Hash)
+	}
+	if got := recoverAddress(t, sig); got != address {
+		t.Fatalf("got signer %s, want %s", got, address)
+	}
+}
+
+const testTypedData = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "string"},
+      {"name": "contents", "type": "string"}
+    ]
+  },
+  "primaryType": "Mail",
+  "domain": {"name": "Ether Mail", "version": "1", "chainId": %d},
+  "message": {"from": "Cow", "contents": "Hello, Bob!"}
+}`
+
+func TestSignTypedData(t *testing.T) {
+	s, address := newTestSigner(t)
+	sig, err := s.SignTypedData(&TypedDataRequest{
+		BusinessId: testBusinessId,
+		Address:    address,
+		Network:    "MainNet",
+		ChainId:    1,
+		TypedData:  []byte(fmt.Sprintf(testTypedData, 1)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recoverAddress(t, sig); got != address {
+		t.Fatalf("got signer %s, want %s", got, address)
+	}
+	_, err = s.SignTypedData(&TypedDataRequest{
+		BusinessId: testBusinessId,
+		Address:    address,
+		Network:    "TestNet",
+		ChainId:    11155111,
+		TypedData:  []byte(fmt.Sprintf(testTypedData, 1)),
+	})
+	if !IsInvalidRequest(err) {
+		t.Fatalf("chain id mismatch: got %v", err)
+	}
+	_, err = s.SignTypedData(&TypedDataRequest{
+		BusinessId: testBusinessId,
+		Address:    address,
+		Network:    "TestNet",
+		TypedData:  []byte(fmt.Sprintf(testTypedData, 11155111)),
+	})
+	if !IsInvalidRequest(err) {
+		t.Fatalf("missing chain id: got %v", err)
+	}
+}
+
+func TestSignOtherBusinessKey(t *testing.T) {
+	s, address := newTestSigner(t)
+	_, err := s.SignMessage(&MessageRequest{BusinessId: "merchant-2", Address: address, Message: "hello"})
+	if !errors.Is(err, ErrKeyNotOwned) {
+		t.Fatalf("other business: got %v, want ErrKeyNotOwned", err)
+	}
+	_, err = s.SignTransaction(&TransactionRequest{BusinessId: "merchant-2", Address: address, ChainId: "1", GasPrice: "1"})
+	if !errors.Is(err, ErrKeyNotOwned) {
+		t.Fatalf("other business: got %v, want ErrKeyNotOwned", err)
+	}
+
+	view := s.keysView.(*memKeysView)
+	view.keys[0].BusinessId = ""
+	view.keys[0].Pooled = true
+	_, err = s.SignMessage(&MessageRequest{Address: address, Message: "hello"})
+	if !errors.Is(err, ErrKeyNotOwned) {
+		t.Fatalf("pooled key: got %v, want ErrKeyNotOwned", err)
+	}
+}
+
+// TestSignStaleReplica signs with a key the pool has just assigned on the
+// primary while the replica still holds it as pooled.
+func TestSignStaleReplica(t *testing.T) {
+	s, address := newTestSigner(t)
+	primary := s.keysView.(*memKeysView)
+	stale := primary.keys[0]
+	stale.BusinessId = ""
+	stale.Pooled = true
+	s.keysView, s.keys = &memKeysView{keys: []database.Keys{stale}}, primary
+
+	if _, err := s.SignMessage(&MessageRequest{BusinessId: testBusinessId, Address: address, Message: "hello"}); err != nil {
+		t.Fatalf("stale pooled replica row: %v", err)
+	}
+	_, err := s.SignMessage(&MessageRequest{BusinessId: "merchant-2", Address: address, Message: "hello"})
+	if !errors.Is(err, ErrKeyNotOwned) {
+		t.Fatalf("other business: got %v, want ErrKeyNotOwned", err)
+	}
+
+	s.keysView = &memKeysView{}
+	if _, err := s.SignMessage(&MessageRequest{BusinessId: testBusinessId, Address: address, Message: "hello"}); err != nil {
+		t.Fatalf("key missing on the replica: %v", err)
+	}
+}
+
+// TestSignWrongChainKey signs with a Bitcoin key on the Ethereum routes,
+// which only accept Ethereum keys and keys stored before the chain was.
+func TestSignWrongChainKey(t *testing.T) {
+	s, address := newTestSigner(t)
+	prvKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := hex.EncodeToString(crypto.CompressPubkey(&prvKey.PublicKey))
+	addTestKey(t, s, prvKey, chains.Bitcoin, chains.MainNet, publicKey, "bc1q-test")
+
+	_, err = s.SignMessage(&MessageRequest{BusinessId: testBusinessId, PublicKey: publicKey, Message: "hello"})
+	if !errors.Is(err, ErrKeyWrongChain) || !IsInvalidRequest(err) {
+		t.Fatalf("message: got %v, want ErrKeyWrongChain", err)
+	}
+	_, err = s.SignTransaction(&TransactionRequest{BusinessId: testBusinessId, PublicKey: publicKey, ChainId: "1", GasPrice: "1"})
+	if !errors.Is(err, ErrKeyWrongChain) {
+		t.Fatalf("transaction: got %v, want ErrKeyWrongChain", err)
+	}
+
+	s.keysView.(*memKeysView).keys[0].Chain = ""
+	if _, err := s.SignMessage(&MessageRequest{BusinessId: testBusinessId, Address: address, Message: "hello"}); err != nil {
+		t.Fatalf("key stored without a chain: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package signer
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/qiaopengjun5162/go-rpc-service/common/metrics"
+	"github.com/qiaopengjun5162/go-rpc-service/services/chains"
+)
+
+type AccessTuple struct {
+	Address     string
+	StorageKeys []string
+}
+
+// TransactionRequest describes an unsigned Ethereum transaction.
+//
+// Amounts are decimal or 0x prefixed hex strings. Type selects the envelope:
+// types.LegacyTxType uses GasPrice, types.AccessListTxType uses GasPrice and
+// AccessList, and types.DynamicFeeTxType uses GasTipCap, GasFeeCap and
+// AccessList. An empty To creates a contract.
+type TransactionRequest struct {
+	// BusinessId is the business of the caller; only its keys can sign.
+	BusinessId string
+	PublicKey  string
+	Address    string
+	ChainId    string
+	Type       uint8
+	Nonce      uint64
+	To         string
+	Value      string
+	Gas        uint64
+	GasPrice   string
+	GasTipCap  string
+	GasFeeCap  string
+	Data       string
+	AccessList []AccessTuple
+}
+
+type SignedTransaction struct {
+	RawTx  string
+	TxHash string
+}
+
+// SignTransaction signs an Ethereum transaction with the stored key for the
+// requested public key or address, which must be an Ethereum key of the
+// caller's business. Ethereum keys sign on every network of the chain.
+//
+// The transaction is signed with the latest signer for the chain id, so
+// legacy transactions are EIP-155 protected.
+//
+// Parameters:
+//   - req: A pointer to a TransactionRequest describing the unsigned transaction.
+//
+// Returns:
+//   - A pointer to a SignedTransaction holding the 0x prefixed raw transaction
+//     and its hash.
+//   - An error wrapping ErrInvalidRequest for malformed requests, ErrKeyNotFound
+//     if the key is unknown, ErrKeyNotOwned if it belongs to another business,
+//     ErrKeyWrongChain if it is not an Ethereum key,
+//     or the underlying error otherwise.
+func (s *Signer) SignTransaction(req *TransactionRequest) (signed *SignedTransaction, err error) {
+	defer func() { metrics.RecordSigning("transaction", err) }()
+	txData, chainId, err := buildTxData(req)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.lookupKey(req.BusinessId, chains.Ethereum, "", req.PublicKey, req.Address)
+	if err != nil {
+		return nil, err
+	}
+	prvKey, err := s.privateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	signedTx, err := types.SignNewTx(prvKey, types.LatestSignerForChainID(chainId), txData)
+	if err != nil {
+		return nil, err
+	}
+	rawTx, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &SignedTransaction{
+		RawTx:  hexutil.Encode(rawTx),
+		TxHash: signedTx.Hash().Hex(),
+	}, nil
+}
+
+// buildTxData validates the request and converts it into the go-ethereum
+// transaction payload for the requested type.
+func buildTxData(req *TransactionRequest) (types.TxData, *big.Int, error) {
+	chainId, err := parseBig("chain_id", req.ChainId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if chainId.Sign() <= 0 {
+		return nil, nil, fmt.Errorf("%w: chain_id is required", ErrInvalidRequest)
+	}
+	var to *common.Address
+	if req.To != "" {
+		if !common.IsHexAddress(req.To) {
+			return nil, nil, fmt.Errorf("%w: invalid to address %q", ErrInvalidRequest, req.To)
+		}
+		toAddr := common.HexToAddress(req.To)
+		to = &toAddr
+	}
+	value, err := parseBig("value", req.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	var data []byte
+	if req.Data != "" {
+		data, err = hexutil.Decode(req.Data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid data: %v", ErrInvalidRequest, err)
+		}
+	}
+	accessList, err := parseAccessList(req.AccessList)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch req.Type {
+	case types.LegacyTxType:
+		if len(accessList) != 0 {
+			return nil, nil, fmt.Errorf("%w: legacy transactions do not support an access list", ErrInvalidRequest)
+		}
+		gasPrice, err := parseRequiredBig("gas_price", req.GasPrice)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &types.LegacyTx{
+			Nonce:    req.Nonce,
+			GasPrice: gasPrice,
+			Gas:      req.Gas,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		}, chainId, nil
+	case types.AccessListTxType:
+		gasPrice, err := parseRequiredBig("gas_price", req.GasPrice)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &types.AccessListTx{
+			ChainID:    chainId,
+			Nonce:      req.Nonce,
+			GasPrice:   gasPrice,
+			Gas:        req.Gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}, chainId, nil
+	case types.DynamicFeeTxType:
+		gasTipCap, err := parseRequiredBig("max_priority_fee_per_gas", req.GasTipCap)
+		if err != nil {
+			return nil, nil, err
+		}
+		gasFeeCap, err := parseRequiredBig("max_fee_per_gas", req.GasFeeCap)
+		if err != nil {
+			return nil, nil, err
+		}
+		if gasFeeCap.Cmp(gasTipCap) < 0 {
+			return nil, nil, fmt.Errorf("%w: max_fee_per_gas is lower than max_priority_fee_per_gas", ErrInvalidRequest)
+		}
+		return &types.DynamicFeeTx{
+			ChainID:    chainId,
+			Nonce:      req.Nonce,
+			GasTipCap:  gasTipCap,
+			GasFeeCap:  gasFeeCap,
+			Gas:        req.Gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}, chainId, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported transaction type %d", ErrInvalidRequest, req.Type)
+	}
+}
+
+func parseAccessList(tuples []AccessTuple) (types.AccessList, error) {
+	accessList := make(types.AccessList, 0, len(tuples))
+	for _, tuple := range tuples {
+		if !common.IsHexAddress(tuple.Address) {
+			return nil, fmt.Errorf("%w: invalid access list address %q", ErrInvalidRequest, tuple.Address)
+		}
+		storageKeys := make([]common.Hash, 0, len(tuple.StorageKeys))
+		for _, storageKey := range tuple.StorageKeys {
+			keyBytes, err := hexutil.Decode(storageKey)
+			if err != nil || len(keyBytes) != common.HashLength {
+				return nil, fmt.Errorf("%w: invalid access list storage key %q", ErrInvalidRequest, storageKey)
+			}
+			storageKeys = append(storageKeys, common.BytesToHash(keyBytes))
+		}
+		accessList = append(accessList, types.AccessTuple{
+			Address:     common.HexToAddress(tuple.Address),
+			StorageKeys: storageKeys,
+		})
+	}
+	return accessList, nil
+}
+
+// parseBig parses an optional decimal or 0x prefixed hex amount, treating an
+// empty string as zero.
+func parseBig(name, value string) (*big.Int, error) {
+	if value == "" {
+		return new(big.Int), nil
+	}
+	n, ok := math.ParseBig256(value)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid %s %q", ErrInvalidRequest, name, value)
+	}
+	return n, nil
+}
+
+func parseRequiredBig(name, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%w: %s is required", ErrInvalidRequest, name)
+	}
+	return parseBig(name, value)
+}